@@ -3,6 +3,7 @@ package backend
 import (
 	"context"
 	"errors"
+	"reflect"
 )
 
 var (
@@ -16,6 +17,45 @@ type Backend interface {
 	Name() string
 }
 
+// A Lister is a Backend able to enumerate the keys it holds.
+// Backends must implement it to load slices and maps of structs.
+type Lister interface {
+	// List returns all the keys starting with the given prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// A Watcher is a Backend able to report when the values it holds change,
+// e.g. to reload the configuration.
+type Watcher interface {
+	// Watch sends a value on the returned channel every time the values held by the backend change.
+	// Notifications are coalesced if they are not consumed in time.
+	// The channel is closed once ctx is done.
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// A KeyChecker is a Backend able to report the values it holds that don't correspond to any key,
+// e.g. to detect typos.
+type KeyChecker interface {
+	// CheckKeys is called by the loader, once the configuration is loaded, with the keys of all the fields.
+	CheckKeys(ctx context.Context, keys []string) error
+}
+
+// A TypedGetter is a Backend holding typed values, e.g. decoded from a file, whose textual form
+// depends on the type of the field they are loaded into.
+type TypedGetter interface {
+	// GetTyped returns the value stored under the given key, formatted to be converted into a value of type t,
+	// e.g. numbers stored into durations are formatted as nanoseconds.
+	GetTyped(ctx context.Context, key string, t reflect.Type) ([]byte, error)
+}
+
+// A ValueGetter is a Backend holding decoded values, e.g. read from a file, that can be stored
+// as is into fields of the same type, such as the dates and times of TOML files.
+type ValueGetter interface {
+	// GetValue returns the value stored under the given key, as decoded.
+	// Times without an explicit offset are expected to be in time.Local.
+	GetValue(ctx context.Context, key string) (any, error)
+}
+
 // Func creates a Backend from a function.
 func Func(name string, fn func(context.Context, string) ([]byte, error)) Backend {
 	return &backendFunc{fn: fn, name: name}
@@ -57,6 +57,44 @@ func (b *Backend) Get(ctx context.Context, key string) ([]byte, error) {
 	return kv.Value, nil
 }
 
+// List returns the keys stored under the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	if b.cache == nil && b.prefetch {
+		err := b.fetchTree(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.cache != nil {
+		var list []string
+		for k := range b.cache {
+			if strings.HasPrefix(k, prefix) {
+				list = append(list, k)
+			}
+		}
+
+		return list, nil
+	}
+
+	var opt api.QueryOptions
+
+	keys, _, err := b.client.KV().Keys(path.Join(b.prefix, prefix), "", opt.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for _, k := range keys {
+		k = strings.TrimPrefix(strings.TrimPrefix(k, b.prefix), "/")
+		if strings.HasPrefix(k, prefix) {
+			list = append(list, k)
+		}
+	}
+
+	return list, nil
+}
+
 func (b *Backend) fetchTree(ctx context.Context) error {
 	var opt api.QueryOptions
 
@@ -43,6 +43,35 @@ func TestConsulBackend(t *testing.T) {
 	})
 }
 
+func TestConsulBackendList(t *testing.T) {
+	prefix := "confita-tests"
+
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+	defer client.KV().DeleteTree(prefix, nil)
+
+	for _, k := range []string{"upstreams/0/host", "upstreams/1/host", "upstreamsx", "other"} {
+		_, err = client.KV().Put(&api.KVPair{Key: prefix + "/" + k, Value: []byte("value")}, nil)
+		require.NoError(t, err)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		b := NewBackend(client, WithPrefix(prefix))
+
+		keys, err := b.List(context.Background(), "upstreams/")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"upstreams/0/host", "upstreams/1/host"}, keys)
+	})
+
+	t.Run("Prefetch", func(t *testing.T) {
+		b := NewBackend(client, WithPrefix(prefix), WithPrefetch())
+
+		keys, err := b.List(context.Background(), "upstreams/")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"upstreams/0/host", "upstreams/1/host"}, keys)
+	})
+}
+
 func TestConsulBackendWithPrefetch(t *testing.T) {
 	prefix := "confita-tests"
 
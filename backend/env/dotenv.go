@@ -0,0 +1,325 @@
+package env
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// NewDotenvBackend creates a configuration loader that loads from a .env file, as used during
+// local development. Keys are looked up as done by NewBackend and the same options can be used.
+// The file is parsed using ParseDotenv the first time a key is requested, and references
+// to undefined variables are resolved using the environment, or using the function set by WithLookupFunc.
+func NewDotenvBackend(path string, opts ...Option) *Backend {
+	return newDotenvBackend(path, false, opts)
+}
+
+// NewOptionalDotenvBackend implementation is exactly the same as NewDotenvBackend except that
+// a missing file is treated as empty.
+func NewOptionalDotenvBackend(path string, opts ...Option) *Backend {
+	return newDotenvBackend(path, true, opts)
+}
+
+func newDotenvBackend(path string, optional bool, opts []Option) *Backend {
+	var vars map[string]string
+
+	b := NewBackend(opts...)
+	b.name = "dotenv"
+
+	// the configured lookup function is only used for references, keys are looked up in the file.
+	lookupRef := b.lookupEnv
+	b.lookupEnv = func(name string) (string, bool) {
+		val, ok := vars[name]
+		return val, ok
+	}
+	b.environ = func() []string {
+		return toEnviron(vars)
+	}
+	b.load = func() error {
+		f, err := os.Open(path)
+		if err != nil {
+			if optional && os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to open file at path %q: %w", path, err)
+		}
+		defer f.Close()
+
+		vars, err = ParseDotenv(f, lookupRef)
+		if err != nil {
+			return fmt.Errorf("failed to decode file %q: %w", path, err)
+		}
+		return nil
+	}
+
+	return b
+}
+
+// ParseDotenv parses the content of a .env file made of KEY=value lines:
+//   - empty lines and lines starting with # are ignored
+//   - keys can be preceded by "export"
+//   - unquoted values end at the end of the line or at a # preceded by a space
+//   - values between single quotes are kept as is and can span multiple lines
+//   - values between double quotes can span multiple lines and contain the \n, \r, \t, \", \\ and \$ escapes
+//   - $VAR, ${VAR} and ${VAR:-default} found in unquoted and double quoted values are replaced by
+//     the value of VAR defined earlier in the file, or by the one returned by lookup if it's not nil
+func ParseDotenv(r io.Reader, lookup func(name string) (string, bool)) (map[string]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := dotenvParser{
+		s:      strings.ReplaceAll(string(data), "\r\n", "\n"),
+		line:   1,
+		vars:   make(map[string]string),
+		lookup: lookup,
+	}
+
+	return p.vars, p.parse()
+}
+
+type dotenvParser struct {
+	s      string
+	i      int
+	line   int
+	vars   map[string]string
+	lookup func(string) (string, bool)
+}
+
+func (p *dotenvParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *dotenvParser) parse() error {
+	for p.i < len(p.s) {
+		p.skipSpaces()
+
+		if p.i == len(p.s) {
+			break
+		}
+		if p.s[p.i] == '\n' {
+			p.i++
+			p.line++
+			continue
+		}
+		if p.s[p.i] == '#' {
+			p.skipLine()
+			continue
+		}
+
+		key := p.readKey()
+		if key == "export" && p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+			p.skipSpaces()
+			key = p.readKey()
+		}
+		if key == "" {
+			return p.errorf("missing variable name")
+		}
+
+		p.skipSpaces()
+		if p.i == len(p.s) || p.s[p.i] != '=' {
+			return p.errorf("missing = after %s", key)
+		}
+		p.i++
+		p.skipSpaces()
+
+		val, err := p.readValue()
+		if err != nil {
+			return err
+		}
+		p.vars[key] = val
+	}
+
+	return nil
+}
+
+func (p *dotenvParser) skipSpaces() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipLine moves to the beginning of the next line.
+func (p *dotenvParser) skipLine() {
+	for p.i < len(p.s) && p.s[p.i] != '\n' {
+		p.i++
+	}
+	if p.i < len(p.s) {
+		p.i++
+		p.line++
+	}
+}
+
+func (p *dotenvParser) readKey() string {
+	start := p.i
+	for p.i < len(p.s) && isKeyChar(p.s[p.i]) {
+		p.i++
+	}
+
+	return p.s[start:p.i]
+}
+
+func isKeyChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *dotenvParser) readValue() (string, error) {
+	if p.i == len(p.s) {
+		return "", nil
+	}
+
+	switch p.s[p.i] {
+	case '\'':
+		end := strings.IndexByte(p.s[p.i+1:], '\'')
+		if end == -1 {
+			return "", p.errorf("missing closing quote")
+		}
+		val := p.s[p.i+1 : p.i+1+end]
+		p.line += strings.Count(val, "\n")
+		p.i += end + 2
+		return val, p.endOfLine()
+	case '"':
+		val, err := p.readDoubleQuoted()
+		if err != nil {
+			return "", err
+		}
+		return val, p.endOfLine()
+	}
+
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] != '\n' {
+		// comments must be preceded by a space
+		if p.s[p.i] == '#' && (p.s[p.i-1] == ' ' || p.s[p.i-1] == '\t') {
+			break
+		}
+		p.i++
+	}
+	val, err := p.expand(strings.TrimRight(p.s[start:p.i], " \t"))
+	if err != nil {
+		return "", err
+	}
+
+	p.skipLine()
+	return val, nil
+}
+
+// endOfLine checks that nothing but a comment follows a quoted value.
+func (p *dotenvParser) endOfLine() error {
+	p.skipSpaces()
+	if p.i < len(p.s) && p.s[p.i] != '\n' && p.s[p.i] != '#' {
+		return p.errorf("unexpected character %q after quoted value", p.s[p.i])
+	}
+
+	p.skipLine()
+	return nil
+}
+
+func (p *dotenvParser) readDoubleQuoted() (string, error) {
+	var sb strings.Builder
+	line := p.line
+
+	for p.i++; p.i < len(p.s); p.i++ {
+		c := p.s[p.i]
+		switch c {
+		case '"':
+			p.i++
+			return sb.String(), nil
+		case '\n':
+			p.line++
+			sb.WriteByte(c)
+		case '\\':
+			if p.i+1 == len(p.s) {
+				continue
+			}
+			p.i++
+			switch e := p.s[p.i]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\', '$':
+				sb.WriteByte(e)
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(e)
+			}
+		case '$':
+			val, n, err := p.expandRef(p.s[p.i:])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(val)
+			p.i += n - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	p.line = line
+	return "", p.errorf("missing closing quote")
+}
+
+// expand replaces the references to variables found in s.
+func (p *dotenvParser) expand(s string) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		val, n, err := p.expandRef(s[i:])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(val)
+		i += n - 1
+	}
+
+	return sb.String(), nil
+}
+
+// expandRef expands the reference at the beginning of s, which starts with a $,
+// and returns its value and its length.
+func (p *dotenvParser) expandRef(s string) (string, int, error) {
+	if len(s) > 1 && s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end == -1 {
+			return "", 0, p.errorf("missing closing brace in %q", s)
+		}
+
+		name, def, hasDef := strings.Cut(s[2:end], ":-")
+		val, ok := p.get(name)
+		if hasDef && (!ok || val == "") {
+			val = def
+		}
+		return val, end + 1, nil
+	}
+
+	n := 1
+	for n < len(s) && s[n] != '.' && s[n] != '-' && isKeyChar(s[n]) {
+		n++
+	}
+	if n == 1 {
+		return "$", 1, nil
+	}
+
+	val, _ := p.get(s[1:n])
+	return val, n, nil
+}
+
+func (p *dotenvParser) get(name string) (string, bool) {
+	if val, ok := p.vars[name]; ok {
+		return val, true
+	}
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+
+	return "", false
+}
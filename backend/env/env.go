@@ -1,25 +1,283 @@
 package env
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/heetch/confita/backend"
 )
 
+// Backend that loads configuration from the environment.
+type Backend struct {
+	name      string
+	prefix    string
+	transform func(key string) string
+	noRawKey  bool
+	empty     bool
+	lookupEnv func(name string) (string, bool)
+	environ   func() []string
+	files     bool
+
+	checkUnknown bool
+
+	// load is called before the first lookup
+	mu     sync.Mutex
+	load   func() error
+	loaded bool
+}
+
+// Option is used to configure the env backend.
+type Option func(*Backend)
+
+// WithPrefix prepends the given prefix, e.g. "MYAPP_", to the transformed keys.
+func WithPrefix(prefix string) Option {
+	return func(b *Backend) {
+		b.prefix = prefix
+	}
+}
+
+// WithKeyTransformer sets the function used to turn keys into variable names,
+// ToUpperSnake by default. See SnakeCase for an alternative.
+func WithKeyTransformer(fn func(key string) string) Option {
+	return func(b *Backend) {
+		b.transform = fn
+	}
+}
+
+// WithoutRawKey prevents the backend from looking up keys as they are written in the struct tags,
+// so that only the transformed and prefixed names are used. This avoids collisions with
+// generic variables such as PORT.
+func WithoutRawKey() Option {
+	return func(b *Backend) {
+		b.noRawKey = true
+	}
+}
+
+// WithEmptyValues makes the backend use the os.LookupEnv semantics: variables that are set
+// but empty are considered as values instead of being ignored, so that they can override
+// the values of the following backends, e.g. FEATURE_X= blanks out feature-x.
+func WithEmptyValues() Option {
+	return func(b *Backend) {
+		b.empty = true
+	}
+}
+
+// WithLookupFunc sets the function used to look up variables, os.LookupEnv by default,
+// e.g. to load the configuration of a child process or to write hermetic tests.
+// With NewDotenvBackend, it is used to resolve the references to variables that are not defined in the file.
+func WithLookupFunc(fn func(name string) (string, bool)) Option {
+	return func(b *Backend) {
+		b.lookupEnv = fn
+		b.environ = nil
+	}
+}
+
+// WithFileVariables makes the backend read the value of a key whose variables are not set from the file
+// referenced by the same variables suffixed with "_FILE", e.g. DB_PASSWORD_FILE=/run/secrets/db,
+// as done by Docker and many Helm charts. A trailing newline is removed from the content of the file.
+// An error is returned if the file can't be read.
+func WithFileVariables() Option {
+	return func(b *Backend) {
+		b.files = true
+	}
+}
+
 // NewBackend creates a configuration loader that loads from the environment.
 // If the key is not found, this backend tries again by turning any kebabcase key to snakecase and
-// lowercase letters to uppercase.
-func NewBackend() backend.Backend {
-	return backend.Func("env", func(ctx context.Context, key string) ([]byte, error) {
-		if val := os.Getenv(key); val != "" {
-			return []byte(val), nil
+// lowercase letters to uppercase. The way keys are turned into variable names can be customized
+// using options.
+func NewBackend(opts ...Option) *Backend {
+	b := Backend{
+		name:      "env",
+		transform: ToUpperSnake,
+		lookupEnv: os.LookupEnv,
+		environ:   os.Environ,
+	}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+// NewBackendFrom creates a configuration loader that loads from the given variables
+// instead of the environment.
+func NewBackendFrom(vars map[string]string, opts ...Option) *Backend {
+	lookup := func(name string) (string, bool) {
+		val, ok := vars[name]
+		return val, ok
+	}
+
+	environ := func() []string {
+		return toEnviron(vars)
+	}
+
+	return NewBackend(append([]Option{WithLookupFunc(lookup), withEnviron(environ)}, opts...)...)
+}
+
+// toEnviron turns vars into the "key=value" form returned by os.Environ.
+func toEnviron(vars map[string]string) []string {
+	env := make([]string, 0, len(vars))
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+
+	return env
+}
+
+func withEnviron(fn func() []string) Option {
+	return func(b *Backend) {
+		b.environ = fn
+	}
+}
+
+// NewBackendFromEnviron creates a configuration loader that loads from the given variables,
+// in the "key=value" form returned by os.Environ and used by exec.Cmd. If a variable is defined
+// several times, the last value is used.
+func NewBackendFromEnviron(environ []string, opts ...Option) *Backend {
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
 		}
-		key = strings.Replace(strings.ToUpper(key), "-", "_", -1)
-		if val := os.Getenv(key); val != "" {
+		vars[k] = v
+	}
+
+	return NewBackendFrom(vars, opts...)
+}
+
+// Get looks up the raw key, unless WithoutRawKey is used, then the transformed key.
+// If WithPrefix is used, the transformed and prefixed key is looked up first and the raw key last.
+// If WithFileVariables is used and none of these variables is set, the "_FILE" variables are looked up in the same order.
+func (b *Backend) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := b.init(); err != nil {
+		return nil, err
+	}
+
+	names := b.names(key)
+	for _, name := range names {
+		if val, ok := b.lookup(name); ok {
 			return []byte(val), nil
 		}
+	}
+
+	if !b.files {
 		return nil, backend.ErrNotFound
-	})
+	}
+
+	// the "_FILE" variables are only used if none of the variables is set.
+	for _, name := range names {
+		path, ok := b.lookup(name + "_FILE")
+		if !ok {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the file referenced by %s_FILE: %w", name, err)
+		}
+		return trimNewline(data), nil
+	}
+
+	return nil, backend.ErrNotFound
+}
+
+func trimNewline(data []byte) []byte {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	return bytes.TrimSuffix(data, []byte("\r"))
+}
+
+// names returns the names of the variables corresponding to the given key, by decreasing priority.
+// With a prefix, the prefixed name comes first so that it isn't shadowed by a generic variable such as PORT.
+func (b *Backend) names(key string) []string {
+	name := b.prefix + b.transform(key)
+	switch {
+	case b.noRawKey:
+		return []string{name}
+	case b.prefix != "":
+		return []string{name, key}
+	default:
+		return []string{key, name}
+	}
+}
+
+func (b *Backend) init() error {
+	if b.load == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.loaded {
+		return nil
+	}
+
+	err := b.load()
+	if err != nil {
+		return err
+	}
+
+	b.loaded = true
+	return nil
+}
+
+// lookup returns the value of the given variable. Empty values are ignored unless WithEmptyValues is used.
+func (b *Backend) lookup(name string) (string, bool) {
+	val, ok := b.lookupEnv(name)
+	if !ok || (val == "" && !b.empty) {
+		return "", false
+	}
+
+	return val, true
+}
+
+// Name returns the name of the backend.
+func (b *Backend) Name() string {
+	return b.name
+}
+
+// ToUpperSnake turns kebabcase keys to snakecase and lowercase letters to uppercase,
+// e.g. "database-url" becomes "DATABASE_URL".
+func ToUpperSnake(key string) string {
+	return strings.Replace(strings.ToUpper(key), "-", "_", -1)
+}
+
+// SnakeCase turns camelcase and kebabcase keys to uppercase snakecase, and dots,
+// which separate the segments of hierarchical keys, to double underscores,
+// e.g. "database.maxIdleConns" becomes "DATABASE__MAX_IDLE_CONNS".
+func SnakeCase(key string) string {
+	var sb strings.Builder
+
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case r == '.':
+			sb.WriteString("__")
+		case r == '-':
+			sb.WriteByte('_')
+		case unicode.IsUpper(r):
+			// start a new word at the first uppercase letter of a word, and at the last one of an acronym,
+			// e.g. "maxIdle" and "HTTPServer"
+			if i > 0 {
+				prev := runes[i-1]
+				next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && next) {
+					sb.WriteByte('_')
+				}
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(unicode.ToUpper(r))
+		}
+	}
+
+	return sb.String()
 }
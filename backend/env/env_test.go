@@ -2,7 +2,10 @@ package env
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/heetch/confita/backend"
@@ -43,3 +46,366 @@ func TestEnvBackend(t *testing.T) {
 		require.Equal(t, "ok", string(val))
 	})
 }
+
+func TestEnvBackendOptions(t *testing.T) {
+	os.Setenv("PORT", "80")
+	os.Setenv("MYAPP_PORT", "8080")
+	os.Setenv("MYAPP_DATABASE__MAX_IDLE_CONNS", "10")
+	defer func() {
+		os.Unsetenv("PORT")
+		os.Unsetenv("MYAPP_PORT")
+		os.Unsetenv("MYAPP_DATABASE__MAX_IDLE_CONNS")
+	}()
+
+	t.Run("Prefix", func(t *testing.T) {
+		b := NewBackend(WithPrefix("MYAPP_"))
+
+		val, err := b.Get(context.Background(), "port")
+		require.NoError(t, err)
+		require.Equal(t, "8080", string(val))
+
+		// the prefixed name is looked up before the raw key
+		val, err = b.Get(context.Background(), "PORT")
+		require.NoError(t, err)
+		require.Equal(t, "8080", string(val))
+
+		// the raw key is still looked up if the prefixed variable isn't set
+		os.Setenv("TIMEOUT", "10s")
+		defer os.Unsetenv("TIMEOUT")
+		val, err = b.Get(context.Background(), "TIMEOUT")
+		require.NoError(t, err)
+		require.Equal(t, "10s", string(val))
+	})
+
+	t.Run("WithoutRawKey", func(t *testing.T) {
+		b := NewBackend(WithPrefix("MYAPP_"), WithoutRawKey())
+
+		val, err := b.Get(context.Background(), "PORT")
+		require.NoError(t, err)
+		require.Equal(t, "8080", string(val))
+
+		_, err = NewBackend(WithPrefix("OTHER_"), WithoutRawKey()).Get(context.Background(), "PORT")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("KeyTransformer", func(t *testing.T) {
+		b := NewBackend(WithPrefix("MYAPP_"), WithKeyTransformer(SnakeCase))
+
+		val, err := b.Get(context.Background(), "database.maxIdleConns")
+		require.NoError(t, err)
+		require.Equal(t, "10", string(val))
+	})
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"port":                  "PORT",
+		"PORT":                  "PORT",
+		"database-url":          "DATABASE_URL",
+		"maxIdleConns":          "MAX_IDLE_CONNS",
+		"HTTPServer":            "HTTP_SERVER",
+		"serverHTTP":            "SERVER_HTTP",
+		"ipv4Address":           "IPV4_ADDRESS",
+		"database.maxIdleConns": "DATABASE__MAX_IDLE_CONNS",
+		"upstreams.0.host":      "UPSTREAMS__0__HOST",
+	}
+
+	for key, name := range tests {
+		require.Equal(t, name, SnakeCase(key), key)
+	}
+}
+
+func TestEnvBackendEmptyValues(t *testing.T) {
+	os.Setenv("FEATURE_X", "")
+	defer os.Unsetenv("FEATURE_X")
+
+	b := NewBackend(WithEmptyValues())
+
+	val, err := b.Get(context.Background(), "feature-x")
+	require.NoError(t, err)
+	require.Equal(t, "", string(val))
+
+	_, err = b.Get(context.Background(), "feature-y")
+	require.Equal(t, backend.ErrNotFound, err)
+
+	_, err = NewBackend().Get(context.Background(), "feature-x")
+	require.Equal(t, backend.ErrNotFound, err)
+}
+
+func TestEnvBackendFrom(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Map", func(t *testing.T) {
+		t.Parallel()
+
+		b := NewBackendFrom(map[string]string{"DATABASE_URL": "postgres://", "EMPTY": ""})
+
+		val, err := b.Get(context.Background(), "database-url")
+		require.NoError(t, err)
+		require.Equal(t, "postgres://", string(val))
+
+		_, err = b.Get(context.Background(), "empty")
+		require.Equal(t, backend.ErrNotFound, err)
+
+		// the process environment is not used
+		_, err = b.Get(context.Background(), "PATH")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("Environ", func(t *testing.T) {
+		t.Parallel()
+
+		b := NewBackendFromEnviron([]string{"MYAPP_PORT=80", "MYAPP_PORT=8080", "MYAPP_URL=http://host/?a=b", "INVALID"}, WithPrefix("MYAPP_"))
+
+		val, err := b.Get(context.Background(), "port")
+		require.NoError(t, err)
+		require.Equal(t, "8080", string(val))
+
+		val, err = b.Get(context.Background(), "url")
+		require.NoError(t, err)
+		require.Equal(t, "http://host/?a=b", string(val))
+	})
+
+	t.Run("LookupFunc", func(t *testing.T) {
+		t.Parallel()
+
+		var names []string
+		b := NewBackend(WithLookupFunc(func(name string) (string, bool) {
+			names = append(names, name)
+			return "", false
+		}))
+
+		_, err := b.Get(context.Background(), "port")
+		require.Equal(t, backend.ErrNotFound, err)
+		require.Equal(t, []string{"port", "PORT"}, names)
+	})
+}
+
+func TestEnvBackendFileVariables(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db")
+	require.NoError(t, os.WriteFile(path, []byte("secret\n"), 0600))
+	crlf := filepath.Join(dir, "crlf")
+	require.NoError(t, os.WriteFile(crlf, []byte("secret\r\n"), 0600))
+
+	vars := map[string]string{
+		"DB_PASSWORD_FILE":  path,
+		"API_KEY":           "key",
+		"API_KEY_FILE":      path,
+		"TOKEN_FILE":        crlf,
+		"MISSING_FILE":      filepath.Join(dir, "missing"),
+		"MYAPP_SECRET_FILE": path,
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		b := NewBackendFrom(vars, WithFileVariables())
+
+		val, err := b.Get(context.Background(), "db-password")
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(val))
+
+		val, err = b.Get(context.Background(), "token")
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(val))
+
+		// the variable takes precedence
+		val, err = b.Get(context.Background(), "api-key")
+		require.NoError(t, err)
+		require.Equal(t, "key", string(val))
+
+		_, err = b.Get(context.Background(), "other")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		val, err := NewBackendFrom(vars, WithFileVariables(), WithPrefix("MYAPP_")).Get(context.Background(), "secret")
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(val))
+	})
+
+	t.Run("VariablesFirst", func(t *testing.T) {
+		vars := map[string]string{
+			"db-password_FILE":       path,
+			"DB_PASSWORD":            "direct",
+			"MYAPP_DB_PASSWORD_FILE": path,
+		}
+
+		// the transformed variable wins over the file referenced by the raw key
+		val, err := NewBackendFrom(vars, WithFileVariables()).Get(context.Background(), "db-password")
+		require.NoError(t, err)
+		require.Equal(t, "direct", string(val))
+
+		// the raw key wins over the file referenced by the prefixed variable
+		val, err = NewBackendFrom(vars, WithFileVariables(), WithPrefix("MYAPP_")).Get(context.Background(), "DB_PASSWORD")
+		require.NoError(t, err)
+		require.Equal(t, "direct", string(val))
+	})
+
+	t.Run("Unreadable", func(t *testing.T) {
+		_, err := NewBackendFrom(vars, WithFileVariables()).Get(context.Background(), "missing")
+		require.Error(t, err)
+		require.NotEqual(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, err := NewBackendFrom(vars).Get(context.Background(), "db-password")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+}
+
+func TestParseDotenv(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		vars, err := ParseDotenv(strings.NewReader(`
+# comment
+PORT=8080
+export HOST = localhost # inline comment
+URL=http://${HOST}:$PORT/#anchor
+EMPTY=
+SINGLE='raw $PORT \n # not a comment'
+DOUBLE="line1\nline2 \"quoted\" \$PORT ${PORT}"
+MULTI="first
+second"
+MULTI_SINGLE='first
+second'
+DEFAULT=${UNDEFINED:-default}
+FROM_ENV=${SHELL_VAR}
+dotted.key=value
+`), func(name string) (string, bool) {
+			if name == "SHELL_VAR" {
+				return "shell", true
+			}
+			return "", false
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"PORT":         "8080",
+			"HOST":         "localhost",
+			"URL":          "http://localhost:8080/#anchor",
+			"EMPTY":        "",
+			"SINGLE":       `raw $PORT \n # not a comment`,
+			"DOUBLE":       "line1\nline2 \"quoted\" $PORT 8080",
+			"MULTI":        "first\nsecond",
+			"MULTI_SINGLE": "first\nsecond",
+			"DEFAULT":      "default",
+			"FROM_ENV":     "shell",
+			"dotted.key":   "value",
+		}, vars)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := map[string]string{
+			"A=1\nB":                "line 2: missing = after B",
+			"A=1\n=2":               "line 2: missing variable name",
+			"A=\"unterminated\nB=1": "line 1: missing closing quote",
+			"A='unterminated":       "line 1: missing closing quote",
+			"A='value' trailing":    "line 1: unexpected character 't' after quoted value",
+			"A=1\nB=${A":            `line 2: missing closing brace in "${A"`,
+		}
+
+		for content, msg := range tests {
+			_, err := ParseDotenv(strings.NewReader(content), nil)
+			require.EqualError(t, err, msg, content)
+		}
+	})
+}
+
+func TestDotenvBackend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(path, []byte("MYAPP_DATABASE_URL=postgres://localhost\nexport MYAPP_PORT=8080\n"), 0600))
+
+	t.Run("OK", func(t *testing.T) {
+		b := NewDotenvBackend(path, WithPrefix("MYAPP_"))
+		require.Equal(t, "dotenv", b.Name())
+
+		val, err := b.Get(context.Background(), "database-url")
+		require.NoError(t, err)
+		require.Equal(t, "postgres://localhost", string(val))
+
+		val, err = b.Get(context.Background(), "port")
+		require.NoError(t, err)
+		require.Equal(t, "8080", string(val))
+
+		_, err = b.Get(context.Background(), "PATH")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("LookupFunc", func(t *testing.T) {
+		refs := filepath.Join(dir, "refs.env")
+		require.NoError(t, os.WriteFile(refs, []byte("URL=postgres://${DB_HOST}/${DB_NAME:-app}\n"), 0600))
+
+		lookup := func(name string) (string, bool) {
+			if name == "DB_HOST" {
+				return "db", true
+			}
+			return "", false
+		}
+
+		val, err := NewDotenvBackend(refs, WithLookupFunc(lookup)).Get(context.Background(), "url")
+		require.NoError(t, err)
+		require.Equal(t, "postgres://db/app", string(val))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := NewDotenvBackend(filepath.Join(dir, "missing")).Get(context.Background(), "port")
+		require.Error(t, err)
+		require.NotEqual(t, backend.ErrNotFound, err)
+
+		_, err = NewOptionalDotenvBackend(filepath.Join(dir, "missing")).Get(context.Background(), "port")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		invalid := filepath.Join(dir, "invalid.env")
+		require.NoError(t, os.WriteFile(invalid, []byte("A"), 0600))
+
+		_, err := NewDotenvBackend(invalid).Get(context.Background(), "a")
+		require.Error(t, err)
+	})
+}
+
+func TestEnvBackendUnknownVariables(t *testing.T) {
+	vars := map[string]string{
+		"MYAPP_TIMOUT":           "10s",
+		"MYAPP_PORT":             "8080",
+		"MYAPP_DB_PASSWORD_FILE": "/run/secrets/db",
+		"MYAPP_SOMETHING_ELSE":   "value",
+		"PATH":                   "/bin",
+	}
+	keys := []string{"timeout", "port", "db-password"}
+
+	t.Run("OK", func(t *testing.T) {
+		b := NewBackendFrom(vars, WithPrefix("MYAPP_"), WithFileVariables(), WithUnknownVariablesCheck())
+
+		err := b.CheckKeys(context.Background(), keys)
+		require.EqualError(t, err, "unknown environment variables: MYAPP_SOMETHING_ELSE, MYAPP_TIMOUT (did you mean MYAPP_TIMEOUT?)")
+
+		var uerr *UnknownVariablesError
+		require.True(t, errors.As(err, &uerr))
+		require.Equal(t, []UnknownVariable{
+			{Name: "MYAPP_SOMETHING_ELSE"},
+			{Name: "MYAPP_TIMOUT", Suggestion: "MYAPP_TIMEOUT"},
+		}, uerr.Variables)
+	})
+
+	t.Run("FileVariables", func(t *testing.T) {
+		b := NewBackendFrom(vars, WithPrefix("MYAPP_"), WithUnknownVariablesCheck())
+
+		var uerr *UnknownVariablesError
+		require.True(t, errors.As(b.CheckKeys(context.Background(), keys), &uerr))
+		require.Contains(t, uerr.Variables, UnknownVariable{Name: "MYAPP_DB_PASSWORD_FILE"})
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		require.NoError(t, NewBackendFrom(vars, WithPrefix("MYAPP_")).CheckKeys(context.Background(), keys))
+		require.NoError(t, NewBackendFrom(vars, WithUnknownVariablesCheck()).CheckKeys(context.Background(), keys))
+	})
+
+	t.Run("Dotenv", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".env")
+		require.NoError(t, os.WriteFile(path, []byte("MYAPP_PROT=8080\n"), 0600))
+
+		err := NewDotenvBackend(path, WithPrefix("MYAPP_"), WithUnknownVariablesCheck()).CheckKeys(context.Background(), keys)
+		require.EqualError(t, err, "unknown environment variables: MYAPP_PROT (did you mean MYAPP_PORT?)")
+	})
+}
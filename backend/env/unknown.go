@@ -0,0 +1,145 @@
+package env
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// WithUnknownVariablesCheck makes the backend report the variables starting with the prefix set
+// using WithPrefix that don't correspond to any field, e.g. MYAPP_TIMOUT, by returning
+// an *UnknownVariablesError from the loader once the configuration is loaded.
+// Since the configuration is fully loaded when the error is returned, it can also be treated as a warning.
+// Variables are only checked if a prefix is set and the variables can be enumerated,
+// i.e. unless WithLookupFunc is used.
+func WithUnknownVariablesCheck() Option {
+	return func(b *Backend) {
+		b.checkUnknown = true
+	}
+}
+
+// An UnknownVariable is a variable that doesn't correspond to any field.
+type UnknownVariable struct {
+	Name string
+	// Suggestion is the name of the expected variable that is the closest to Name,
+	// if any is close enough.
+	Suggestion string
+}
+
+func (v UnknownVariable) String() string {
+	if v.Suggestion == "" {
+		return v.Name
+	}
+
+	return v.Name + " (did you mean " + v.Suggestion + "?)"
+}
+
+// UnknownVariablesError is returned when variables don't correspond to any field.
+type UnknownVariablesError struct {
+	Variables []UnknownVariable
+}
+
+func (e *UnknownVariablesError) Error() string {
+	vars := make([]string, len(e.Variables))
+	for i, v := range e.Variables {
+		vars[i] = v.String()
+	}
+
+	return "unknown environment variables: " + strings.Join(vars, ", ")
+}
+
+// CheckKeys implements backend.KeyChecker. If WithUnknownVariablesCheck is used, it returns
+// an *UnknownVariablesError if some variables starting with the prefix don't correspond to any of the keys.
+func (b *Backend) CheckKeys(ctx context.Context, keys []string) error {
+	if !b.checkUnknown || b.prefix == "" || b.environ == nil {
+		return nil
+	}
+
+	if err := b.init(); err != nil {
+		return err
+	}
+
+	expected := make(map[string]bool)
+	for _, k := range keys {
+		for _, name := range b.names(k) {
+			expected[name] = true
+			if b.files {
+				expected[name+"_FILE"] = true
+			}
+		}
+	}
+
+	candidates := make([]string, 0, len(expected))
+	for name := range expected {
+		if strings.HasPrefix(name, b.prefix) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+
+	var unknown []UnknownVariable
+	for _, kv := range b.environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, b.prefix) || expected[name] {
+			continue
+		}
+
+		unknown = append(unknown, UnknownVariable{
+			Name:       name,
+			Suggestion: suggest(name, candidates),
+		})
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Slice(unknown, func(i, j int) bool {
+		return unknown[i].Name < unknown[j].Name
+	})
+	return &UnknownVariablesError{Variables: unknown}
+}
+
+// suggest returns the candidate that is the closest to name, if the edit distance
+// between them is small enough for it to be a typo.
+func suggest(name string, candidates []string) string {
+	var (
+		best     string
+		bestDist int
+	)
+	for _, c := range candidates {
+		d := editDistance(name, c)
+		if best == "" || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	if best == "" || bestDist > 3 || bestDist*3 > len(name) {
+		return ""
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
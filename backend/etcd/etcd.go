@@ -55,6 +55,42 @@ func (b *Backend) Get(ctx context.Context, key string) ([]byte, error) {
 	return resp.Kvs[0].Value, nil
 }
 
+// List returns the keys stored under the given prefix.
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	if b.cache == nil && b.prefetch {
+		err := b.fetchTree(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.cache != nil {
+		var list []string
+		for k := range b.cache {
+			if strings.HasPrefix(k, prefix) {
+				list = append(list, k)
+			}
+		}
+
+		return list, nil
+	}
+
+	resp, err := b.client.Get(ctx, path.Join(b.prefix, prefix), clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for _, kv := range resp.Kvs {
+		k := strings.TrimPrefix(strings.TrimPrefix(string(kv.Key), b.prefix), "/")
+		if strings.HasPrefix(k, prefix) {
+			list = append(list, k)
+		}
+	}
+
+	return list, nil
+}
+
 func (b *Backend) fetchTree(ctx context.Context) error {
 	resp, err := b.client.KV.Get(ctx, b.prefix, clientv3.WithPrefix())
 	if err != nil {
@@ -21,6 +21,41 @@ func TestEtcdBackend(t *testing.T) {
 	require.Equal(t, backend.ErrNotFound, err)
 }
 
+func TestEtcdBackendList(t *testing.T) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{"localhost:2379"},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	prefix := "confita-tests"
+
+	ctx := context.Background()
+
+	defer client.KV.Delete(ctx, prefix, clientv3.WithPrefix())
+
+	for _, k := range []string{"upstreams/0/host", "upstreams/1/host", "upstreamsx", "other"} {
+		_, err = client.KV.Put(ctx, prefix+"/"+k, "value")
+		require.NoError(t, err)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		b := NewBackend(client, WithPrefix(prefix))
+
+		keys, err := b.List(ctx, "upstreams/")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"upstreams/0/host", "upstreams/1/host"}, keys)
+	})
+
+	t.Run("Prefetch", func(t *testing.T) {
+		b := NewBackend(client, WithPrefix(prefix), WithPrefetch())
+
+		keys, err := b.List(ctx, "upstreams/")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"upstreams/0/host", "upstreams/1/host"}, keys)
+	})
+}
+
 func TestEtcdBackendWithPrefetch(t *testing.T) {
 	client, err := clientv3.New(clientv3.Config{
 		Endpoints: []string{"localhost:2379"},
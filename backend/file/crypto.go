@@ -0,0 +1,206 @@
+package file
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Decryptor decrypts the content of encrypted files or values.
+// It allows secrets to be committed alongside the rest of the configuration
+// and to be decrypted when it gets loaded.
+type Decryptor interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// DecryptorFunc is an adapter allowing to use a function as a Decryptor,
+// e.g. to rely on an age identity or a key management service.
+type DecryptorFunc func(ciphertext []byte) ([]byte, error)
+
+// Decrypt calls fn(ciphertext).
+func (fn DecryptorFunc) Decrypt(ciphertext []byte) ([]byte, error) {
+	return fn(ciphertext)
+}
+
+// WithDecryptor makes the backend decrypt whole files using d before decoding them.
+// The content of the files can either be the raw ciphertext or its base64 encoding.
+// The ".enc" extension is ignored when detecting the format of a file,
+// e.g. "config.yaml.enc" is decoded as yaml.
+func WithDecryptor(d Decryptor) Option {
+	return func(b *Backend) {
+		b.decryptor = d
+	}
+}
+
+// WithEncryptedValues makes the backend decrypt, using d, the string values
+// of the form "ENC[<base64 ciphertext>]" once files are decoded, e.g.
+//
+//	database:
+//	  password: ENC[2xEbY0c1iFfIJ8GeZIyq4mB9K3w1bq0tWwjdbA==]
+//
+// Other values are left untouched.
+func WithEncryptedValues(d Decryptor) Option {
+	return func(b *Backend) {
+		b.valueDecryptor = d
+	}
+}
+
+// AESGCM encrypts and decrypts content using AES in Galois/Counter Mode.
+// Ciphertexts are made of a random nonce followed by the sealed content.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM creates an AESGCM using the given 16, 24 or 32 bytes key,
+// to select AES-128, AES-192 or AES-256.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid AES key")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESGCM{aead: aead}, nil
+}
+
+// NewAESGCMFromEnv creates an AESGCM using the base64 encoded key
+// stored in the given environment variable.
+func NewAESGCMFromEnv(name string) (*AESGCM, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.Errorf("environment variable \"%s\" is not set", name)
+	}
+
+	key, err := decodeKey(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid key in environment variable \"%s\"", name)
+	}
+
+	return NewAESGCM(key)
+}
+
+// NewAESGCMFromFile creates an AESGCM using the base64 encoded key stored in the given file.
+func NewAESGCMFromFile(path string) (*AESGCM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read key file \"%s\"", path)
+	}
+
+	key, err := decodeKey(string(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid key in file \"%s\"", path)
+	}
+
+	return NewAESGCM(key)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
+
+// Encrypt encrypts plaintext using a random nonce.
+func (a *AESGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return a.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// EncryptValue encrypts plaintext and returns it in the form expected by WithEncryptedValues.
+func (a *AESGCM) EncryptValue(plaintext string) (string, error) {
+	ciphertext, err := a.Encrypt([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return encPrefix + base64.StdEncoding.EncodeToString(ciphertext) + encSuffix, nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt.
+func (a *AESGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := a.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return a.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+const (
+	encPrefix = "ENC["
+	encSuffix = "]"
+)
+
+// decryptFile decrypts the content of a whole file, either raw or base64 encoded.
+func decryptFile(d Decryptor, data []byte) ([]byte, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data))); err == nil {
+		data = decoded
+	}
+
+	plaintext, err := d.Decrypt(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt content")
+	}
+
+	return plaintext, nil
+}
+
+// decryptValues replaces the encrypted string values found in node by their plaintext.
+func decryptValues(d Decryptor, node any, key string) (any, error) {
+	var err error
+
+	switch t := node.(type) {
+	case map[string]any:
+		for k, v := range t {
+			t[k], err = decryptValues(d, v, join(key, k))
+			if err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, v := range t {
+			t[i], err = decryptValues(d, v, join(key, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+		}
+	case []map[string]any:
+		for i, v := range t {
+			_, err = decryptValues(d, v, join(key, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+		}
+	case string:
+		if !strings.HasPrefix(t, encPrefix) || !strings.HasSuffix(t, encSuffix) {
+			return t, nil
+		}
+
+		ciphertext, err := base64.StdEncoding.DecodeString(t[len(encPrefix) : len(t)-len(encSuffix)])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid encrypted value for key \"%s\"", key)
+		}
+
+		plaintext, err := d.Decrypt(ciphertext)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt value for key \"%s\"", key)
+		}
+		return string(plaintext), nil
+	}
+
+	return node, nil
+}
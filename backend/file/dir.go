@@ -0,0 +1,215 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Option is used to configure the file backends.
+type Option func(*Backend)
+
+// WithAppendLists makes NewDirBackend concatenate the lists defined in several files
+// instead of replacing them.
+func WithAppendLists() Option {
+	return func(b *Backend) {
+		b.appendLists = true
+	}
+}
+
+// WithConflictHandler registers a function called by NewDirBackend every time a value defined
+// in a file is redefined by a later one. If fn returns an error, loading is aborted.
+func WithConflictHandler(fn func(Conflict) error) Option {
+	return func(b *Backend) {
+		b.onConflict = fn
+	}
+}
+
+// A Conflict describes a value defined in a file and redefined in another one.
+type Conflict struct {
+	// Key of the redefined value, e.g. "database.uri".
+	Key string
+	// Previous is the path of the file that defined the value first.
+	Previous string
+	// File is the path of the file that redefined the value.
+	File string
+}
+
+// NewDirBackend creates a configuration loader that loads all the files of dir
+// matching the given pattern, e.g. "*.yaml", in lexical order and merges them:
+//   - objects are merged recursively
+//   - lists are replaced, unless the WithAppendLists option is used
+//   - other values are replaced
+//
+// Values defined in a file thus take precedence over the ones defined in the files before it.
+// A missing directory or a directory without any matching file is treated as empty.
+func NewDirBackend(dir, pattern string, opts ...Option) *Backend {
+	name := strings.TrimPrefix(filepath.Ext(pattern), ".")
+	if name == "" {
+		name = "dir"
+	}
+
+	b := Backend{
+		path: dir,
+		name: name,
+	}
+	b.read = func(ctx context.Context) (map[string]any, error) {
+		return b.loadDir(ctx, dir, pattern)
+	}
+	b.candidates = func() ([]string, []string) {
+		paths, _ := filepath.Glob(filepath.Join(dir, pattern))
+		return paths, []string{dir}
+	}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+func (b *Backend) loadDir(ctx context.Context, dir, pattern string) (map[string]any, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid pattern \"%s\"", pattern)
+	}
+	sort.Strings(paths)
+
+	var files []string
+	m := merger{
+		doc:         make(map[string]any),
+		origins:     make(map[string]string),
+		appendLists: b.appendLists,
+		onConflict:  b.onConflict,
+	}
+
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open file at path \"%s\"", path)
+		}
+		if fi.IsDir() {
+			continue
+		}
+
+		doc, err := b.fileBackend(path).load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.merge(m.doc, doc, "", path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+
+	// later files take precedence
+	for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+		files[i], files[j] = files[j], files[i]
+	}
+	b.files = files
+
+	return m.doc, nil
+}
+
+type merger struct {
+	doc         map[string]any
+	origins     map[string]string
+	appendLists bool
+	onConflict  func(Conflict) error
+}
+
+// merge merges src, decoded from the given file, into dst.
+func (m *merger) merge(dst, src map[string]any, prefix, file string) error {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := join(prefix, k)
+		sv := src[k]
+
+		dv, ok := dst[k]
+		if !ok {
+			dst[k] = sv
+			m.origins[key] = file
+			continue
+		}
+
+		dm, dIsMap := dv.(map[string]any)
+		sm, sIsMap := sv.(map[string]any)
+		if dIsMap && sIsMap {
+			err := m.merge(dm, sm, key, file)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		dl, dIsList := toList(dv)
+		sl, sIsList := toList(sv)
+		if m.appendLists && dIsList && sIsList {
+			dst[k] = append(dl, sl...)
+			continue
+		}
+
+		if m.onConflict != nil {
+			err := m.onConflict(Conflict{
+				Key:      key,
+				Previous: m.origin(key),
+				File:     file,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// the previous value is replaced, along with the origins of its content
+		for o := range m.origins {
+			if strings.HasPrefix(o, key+".") {
+				delete(m.origins, o)
+			}
+		}
+		dst[k] = sv
+		m.origins[key] = file
+	}
+
+	return nil
+}
+
+// origin returns the file that defined the given key or its closest parent.
+func (m *merger) origin(key string) string {
+	for {
+		if o, ok := m.origins[key]; ok {
+			return o
+		}
+
+		idx := strings.LastIndex(key, ".")
+		if idx == -1 {
+			return ""
+		}
+		key = key[:idx]
+	}
+}
+
+func toList(v any) ([]any, bool) {
+	switch t := v.(type) {
+	case []any:
+		return t, true
+	case []map[string]any:
+		l := make([]any, len(t))
+		for i := range t {
+			l[i] = t[i]
+		}
+		return l, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,137 @@
+package file
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithEnvExpansion makes the backend replace shell-style variables found in files before decoding them:
+//   - $VAR and ${VAR} are replaced by the value of VAR, or by an empty string if it's not set
+//   - ${VAR:-default} is replaced by default if VAR is not set or empty, ${VAR-default} if it's not set
+//   - ${VAR:?message} fails with the given message if VAR is not set or empty, ${VAR?message} if it's not set
+//   - $$ is replaced by $
+//
+// Variables are resolved using lookup, or os.LookupEnv if nil.
+func WithEnvExpansion(lookup func(name string) (string, bool)) Option {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	return func(b *Backend) {
+		b.lookupEnv = lookup
+	}
+}
+
+// expandEnv replaces the variables found in s.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch c := s[i+1]; {
+		case c == '$':
+			b.WriteByte('$')
+			i++
+		case c == '{':
+			end := closingBrace(s, i+2)
+			if end == -1 {
+				return "", errors.Errorf("missing closing brace in \"%s\"", s[i:])
+			}
+			v, err := expandExpr(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		case isNameChar(c, true):
+			j := i + 2
+			for j < len(s) && isNameChar(s[j], false) {
+				j++
+			}
+			v, _ := lookup(s[i+1 : j])
+			b.WriteString(v)
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the expression starting at start.
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// expandExpr evaluates the content of a ${...} expression.
+func expandExpr(expr string, lookup func(string) (string, bool)) (string, error) {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n], n == 0) {
+		n++
+	}
+	if n == 0 {
+		return "", errors.Errorf("invalid variable name in \"${%s}\"", expr)
+	}
+
+	name, op := expr[:n], expr[n:]
+	v, set := lookup(name)
+
+	if op == "" {
+		return v, nil
+	}
+
+	// with a colon, empty variables are considered unset
+	orEmpty := strings.HasPrefix(op, ":")
+	if orEmpty {
+		op = op[1:]
+		set = set && v != ""
+	}
+
+	if op == "" {
+		return "", errors.Errorf("invalid expression \"${%s}\"", expr)
+	}
+
+	switch op[0] {
+	case '-':
+		if set {
+			return v, nil
+		}
+		return expandEnv(op[1:], lookup)
+	case '?':
+		if set {
+			return v, nil
+		}
+		msg := op[1:]
+		if msg == "" {
+			msg = "not set"
+		}
+		return "", errors.Errorf("variable %s: %s", name, msg)
+	}
+
+	return "", errors.Errorf("invalid expression \"${%s}\"", expr)
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
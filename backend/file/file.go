@@ -1,86 +1,485 @@
 package file
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/heetch/confita/backend"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 // Backend that loads a configuration from a file.
-// It supports json and yaml formats.
+// It supports json, jsonc, yaml, toml, hcl, ini and properties formats,
+// and can be extended using RegisterFormat.
 type Backend struct {
 	path     string
 	name     string
+	ext      string
 	optional bool
+	fsys     fs.FS
+	open     func(ctx context.Context) (io.ReadCloser, error)
+	read     func(ctx context.Context) (map[string]any, error)
+
+	mu    sync.Mutex
+	doc   map[string]any
+	files []string
+
+	appendLists bool
+	onConflict  func(Conflict) error
+	lookupEnv   func(string) (string, bool)
+	includeKey  string
+
+	decryptor      Decryptor
+	valueDecryptor Decryptor
+
+	remote     *remote
+	httpClient *http.Client
+	header     http.Header
+
+	candidates   func() (files, dirs []string)
+	debounce     time.Duration
+	pollInterval time.Duration
+	forcePoll    bool
 }
 
-// NewBackend creates a configuration loader that loads from a file.
-// The content will get decoded based on the file extension.
-// If optional parameter is set to true, calling Unmarshal won't return an error if the file doesn't exist.
-func NewBackend(path string) *Backend {
-	name := filepath.Ext(path)
-	if name != "" {
-		name = name[1:]
+func newBackend(path, ext string, open func(ctx context.Context) (io.ReadCloser, error), opts []Option) *Backend {
+	b := Backend{
+		path: path,
+		name: strings.TrimPrefix(ext, "."),
+		ext:  ext,
+		open: open,
 	}
+	b.read = b.load
 
-	return &Backend{
-		path: path,
-		name: name,
+	for _, opt := range opts {
+		opt(&b)
 	}
+
+	return &b
+}
+
+// fileBackend creates a backend loading the given file with the same options as b.
+func (b *Backend) fileBackend(path string) *Backend {
+	var fb *Backend
+	switch {
+	case b.fsys != nil:
+		fb = NewFSBackend(b.fsys, path)
+	case b.remote != nil:
+		fb = NewHTTPBackend(path)
+		fb.httpClient = b.httpClient
+		fb.header = b.header
+	default:
+		fb = NewBackend(path)
+	}
+	fb.lookupEnv = b.lookupEnv
+	fb.includeKey = b.includeKey
+	fb.appendLists = b.appendLists
+	fb.onConflict = b.onConflict
+	fb.decryptor = b.decryptor
+	fb.valueDecryptor = b.valueDecryptor
+	return fb
+}
+
+// NewBackend creates a configuration loader that loads from a file.
+// The content will get decoded based on the file extension.
+// If optional parameter is set to true, calling Get won't return an error if the file doesn't exist.
+func NewBackend(path string, opts ...Option) *Backend {
+	return newBackend(path, filepath.Ext(path), func(context.Context) (io.ReadCloser, error) {
+		return os.Open(path)
+	}, opts)
 }
 
 // NewOptionalBackend implementation is exactly the same as NewBackend except that
 // if the file is not found, backend.ErrNotFound will be returned.
-func NewOptionalBackend(path string) *Backend {
-	name := filepath.Ext(path)
-	if name != "" {
-		name = name[1:]
+func NewOptionalBackend(path string, opts ...Option) *Backend {
+	b := NewBackend(path, opts...)
+	b.optional = true
+	return b
+}
+
+// NewFSBackend creates a configuration loader that loads from a file of the given file system,
+// e.g. an embed.FS. The content will get decoded based on the file extension.
+func NewFSBackend(fsys fs.FS, path string, opts ...Option) *Backend {
+	b := newBackend(path, filepath.Ext(path), func(context.Context) (io.ReadCloser, error) {
+		return fsys.Open(path)
+	}, opts)
+	b.fsys = fsys
+	return b
+}
+
+// NewOptionalFSBackend implementation is exactly the same as NewFSBackend except that
+// if the file is not found, backend.ErrNotFound will be returned.
+func NewOptionalFSBackend(fsys fs.FS, path string, opts ...Option) *Backend {
+	b := NewFSBackend(fsys, path, opts...)
+	b.optional = true
+	return b
+}
+
+// NewReaderBackend creates a configuration loader that loads from r, which is decoded
+// according to the given format, e.g. "yaml". The content of r is only read once.
+func NewReaderBackend(r io.Reader, format string, opts ...Option) *Backend {
+	var (
+		once sync.Once
+		data []byte
+		err  error
+	)
+
+	// the content is kept so that it can be decoded again, e.g. after a decoding error.
+	return newBackend("", "."+strings.TrimPrefix(format, "."), func(context.Context) (io.ReadCloser, error) {
+		once.Do(func() {
+			data, err = io.ReadAll(r)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, opts)
+}
+
+// NewBytesBackend creates a configuration loader that loads from data, which is decoded
+// according to the given format, e.g. "yaml".
+func NewBytesBackend(data []byte, format string, opts ...Option) *Backend {
+	return NewReaderBackend(bytes.NewReader(data), format, opts...)
+}
+
+// Get returns the value stored under the given key.
+// The file is decoded, using the decoder registered for its extension,
+// the first time a key is requested and kept in memory.
+// Nested objects can be reached using dots, e.g. "database.uri".
+func (b *Backend) Get(ctx context.Context, key string) ([]byte, error) {
+	doc, err := b.document(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := lookup(doc, key)
+	if !ok || v == nil {
+		return nil, backend.ErrNotFound
+	}
+
+	return []byte(format(v, nil)), nil
+}
+
+// GetValue implements backend.ValueGetter. It returns the value stored under the given key as decoded.
+func (b *Backend) GetValue(ctx context.Context, key string) (any, error) {
+	doc, err := b.document(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := lookup(doc, key)
+	if !ok || v == nil {
+		return nil, backend.ErrNotFound
+	}
+
+	return v, nil
+}
+
+// GetTyped implements backend.TypedGetter. It works like Get, except that numbers stored
+// into durations are considered to be nanoseconds, like encoding/json does.
+func (b *Backend) GetTyped(ctx context.Context, key string, t reflect.Type) ([]byte, error) {
+	doc, err := b.document(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Backend{
-		path:     path,
-		name:     name,
-		optional: true,
+	v, ok := lookup(doc, key)
+	if !ok || v == nil {
+		return nil, backend.ErrNotFound
+	}
+
+	return []byte(format(v, t)), nil
+}
+
+// List returns the keys starting with the given prefix. Keys of nested objects
+// and lists are joined using dots, e.g. "upstreams.0.host".
+func (b *Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	doc, err := b.document(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	var keys []string
+	flatten(doc, "", func(key string) {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	})
+
+	return keys, nil
+}
+
+// Files returns the paths of the files the configuration was loaded from,
+// by decreasing priority. It is empty until a key has been requested.
+func (b *Backend) Files() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.files
+}
+
+func (b *Backend) document(ctx context.Context) (map[string]any, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.doc != nil {
+		return b.doc, nil
+	}
+
+	doc, err := b.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+
+	b.doc = doc
+	return doc, nil
+}
+
+func (b *Backend) load(ctx context.Context) (map[string]any, error) {
+	return b.loadIncluding(ctx, nil)
 }
 
-// Unmarshal takes a struct pointer and unmarshals the file into it,
-// using either json or yaml based on the file extention.
-func (b *Backend) Unmarshal(ctx context.Context, to any) error {
-	f, err := os.Open(b.path)
+// loadIncluding loads the file, which is included by the files of the given stack.
+func (b *Backend) loadIncluding(ctx context.Context, stack []string) (map[string]any, error) {
+	f, err := b.open(ctx)
 	if err != nil {
 		if b.optional {
-			return backend.ErrNotFound
+			return nil, backend.ErrNotFound
 		}
-		return errors.Wrapf(err, "failed to open file at path \"%s\"", b.path)
+		return nil, errors.Wrapf(err, "failed to open file at path \"%s\"", b.path)
 	}
 	defer f.Close()
 
-	switch ext := filepath.Ext(b.path); ext {
-	case ".json":
-		err = json.NewDecoder(f).Decode(to)
-	case ".yml":
-		fallthrough
-	case ".yaml":
-		err = yaml.NewDecoder(f).Decode(to)
-	case ".toml":
-		_, err = toml.DecodeReader(f, to)
-	default:
-		err = errors.Errorf("unsupported extension \"%s\"", ext)
+	var r io.Reader = f
+	if b.lookupEnv != nil || b.decryptor != nil {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read file at path \"%s\"", b.path)
+		}
+
+		if b.decryptor != nil {
+			data, err = decryptFile(b.decryptor, data)
+			if err != nil {
+				return nil, b.decodeError(err)
+			}
+		}
+
+		if b.lookupEnv != nil {
+			expanded, err := expandEnv(string(data), b.lookupEnv)
+			if err != nil {
+				return nil, b.decodeError(err)
+			}
+			data = []byte(expanded)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	doc, err := decode(b.formatExt(), r)
+	if err != nil {
+		return nil, b.decodeError(err)
 	}
 
+	if b.valueDecryptor != nil {
+		_, err = decryptValues(b.valueDecryptor, doc, "")
+		if err != nil {
+			return nil, b.decodeError(err)
+		}
+	}
+
+	if b.path != "" {
+		b.files = []string{b.path}
+	}
+
+	if b.includeKey != "" {
+		return b.resolveIncludes(ctx, doc, stack)
+	}
+	return doc, nil
+}
+
+// formatExt returns the extension of the format used to decode the file.
+// The ".enc" extension of encrypted files is ignored, and the format of the files
+// fetched over HTTP can be detected using their Content-Type.
+func (b *Backend) formatExt() string {
+	ext := b.ext
+	if b.decryptor != nil && ext == ".enc" && b.path != "" {
+		p := b.path
+		if b.remote != nil {
+			p = urlPath(p)
+		}
+		ext = filepath.Ext(strings.TrimSuffix(p, ext))
+	}
+
+	if _, ok := lookupFormat(ext); !ok && b.remote != nil {
+		if ct, ok := b.contentTypeExt(); ok {
+			return ct
+		}
+	}
+
+	return ext
+}
+
+func (b *Backend) decodeError(err error) error {
+	if b.path == "" {
+		return errors.Wrapf(err, "failed to decode %s content", b.name)
+	}
 	return errors.Wrapf(err, "failed to decode file \"%s\"", b.path)
 }
 
-// Get is not implemented.
-func (b *Backend) Get(ctx context.Context, key string) ([]byte, error) {
-	return nil, errors.New("not implemented")
+// decode parses the content of r using the decoder registered for the given extension.
+func decode(ext string, r io.Reader) (map[string]any, error) {
+	dec, ok := lookupFormat(ext)
+	if !ok {
+		return nil, errors.Errorf("unsupported extension \"%s\"", ext)
+	}
+
+	return dec(r)
+}
+
+// normalize turns the map[any]any values produced by yaml into map[string]any.
+func normalize(v any) any {
+	switch t := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []any:
+		for i := range t {
+			t[i] = normalize(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// lookup returns the value stored under key in the given node.
+// Dots are used to reach the content of nested maps and slices,
+// the longest matching key being tried first.
+func lookup(node any, key string) (any, bool) {
+	if v, ok := child(node, key); ok {
+		return v, true
+	}
+
+	for i := len(key) - 1; i > 0; i-- {
+		if key[i] != '.' {
+			continue
+		}
+
+		if c, ok := child(node, key[:i]); ok {
+			if v, ok := lookup(c, key[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// flatten calls fn with the key of every value stored in node.
+func flatten(node any, prefix string, fn func(key string)) {
+	switch t := node.(type) {
+	case map[string]any:
+		for k, v := range t {
+			flatten(v, join(prefix, k), fn)
+		}
+		return
+	case []any:
+		for i, v := range t {
+			flatten(v, join(prefix, strconv.Itoa(i)), fn)
+		}
+		return
+	case []map[string]any:
+		for i, v := range t {
+			flatten(v, join(prefix, strconv.Itoa(i)), fn)
+		}
+		return
+	}
+
+	fn(prefix)
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
+
+// child returns the value stored under the given map key or slice index.
+// The keys of a list made of a single object, such as a single HCL block, are looked up in that object.
+func child(node any, key string) (any, bool) {
+	switch t := node.(type) {
+	case map[string]any:
+		v, ok := t[key]
+		return v, ok
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil && len(t) == 1 {
+			if _, ok := t[0].(map[string]any); ok {
+				return child(t[0], key)
+			}
+		}
+		if err != nil || i < 0 || i >= len(t) {
+			return nil, false
+		}
+		return t[i], true
+	case []map[string]any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(t) {
+			return nil, false
+		}
+		return t[i], true
+	}
+
+	return nil, false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// format turns a decoded value into a string that can be passed to FieldConfig.Set.
+// If t is a duration, integers are considered to be nanoseconds.
+// Lists and objects are turned into JSON.
+func format(v any, t reflect.Type) string {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number, int, int64, uint64, bool:
+		s := fmt.Sprint(v)
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil && t == durationType {
+			return s + "ns"
+		}
+		return s
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
 }
 
 // Name returns the type of the file.
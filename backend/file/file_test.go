@@ -1,13 +1,25 @@
 package file_test
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/heetch/confita"
 	"github.com/heetch/confita/backend"
 	"github.com/heetch/confita/backend/file"
 	"github.com/stretchr/testify/require"
@@ -35,9 +47,9 @@ func createTempFile(t *testing.T, name, content string) (string, func()) {
 
 func TestFileBackend(t *testing.T) {
 	type config struct {
-		Name    string
-		Age     int
-		Timeout time.Duration
+		Name    string        `config:"name"`
+		Age     int           `config:"age"`
+		Timeout time.Duration `config:"timeout"`
 	}
 
 	ekv := config{
@@ -49,7 +61,7 @@ func TestFileBackend(t *testing.T) {
 	testLoad := func(t *testing.T, path string, template any, expected any) {
 		b := file.NewBackend(path)
 
-		err := b.Unmarshal(context.Background(), template)
+		err := confita.NewLoader(b).Load(context.Background(), template)
 		require.NoError(t, err)
 		require.EqualValues(t, expected, template)
 	}
@@ -98,16 +110,16 @@ timeout = 10
 `)
 			defer cleanup()
 			type Config struct {
-				Title  string
-				Config config
+				Title   string        `config:"title"`
+				Name    string        `config:"Config.name"`
+				Age     int           `config:"Config.age"`
+				Timeout time.Duration `config:"Config.timeout"`
 			}
 			e := Config{
-				Title: "title!",
-				Config: config{
-					Name:    "some name",
-					Age:     10,
-					Timeout: 10,
-				},
+				Title:   "title!",
+				Name:    "some name",
+				Age:     10,
+				Timeout: 10,
 			}
 
 			testLoad(t, path, &Config{}, &e)
@@ -126,13 +138,224 @@ timeout = 11
 `)
 			defer cleanup()
 			type Configs struct {
-				Config []config
+				Config []config `config:"Config"`
 			}
 			e := Configs{Config: []config{{Name: "Alice", Age: 10, Timeout: 10}, {Name: "Bob", Age: 11, Timeout: 11}}}
 
 			testLoad(t, path, &Configs{}, &e)
 		})
 
+		t.Run("TOML Dates", func(t *testing.T) {
+			type config struct {
+				Cutoff  time.Time `config:"cutoff,layout=date"`
+				Local   time.Time `config:"local,tz=Europe/Paris"`
+				Instant time.Time `config:"instant,layout=date"`
+			}
+
+			var c config
+			b := file.NewBytesBackend([]byte("cutoff = 2019-03-04\nlocal = 2019-03-04T10:00:00\ninstant = 2019-03-04T10:00:00+02:00\n"), "toml")
+			err := confita.NewLoader(b).Load(context.Background(), &c)
+			require.NoError(t, err)
+
+			paris, err := time.LoadLocation("Europe/Paris")
+			require.NoError(t, err)
+			require.Equal(t, time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC), c.Cutoff)
+			require.Equal(t, time.Date(2019, 3, 4, 10, 0, 0, 0, paris), c.Local)
+			require.True(t, time.Date(2019, 3, 4, 8, 0, 0, 0, time.UTC).Equal(c.Instant))
+		})
+	})
+
+	t.Run("Composite", func(t *testing.T) {
+		type upstream struct {
+			Host string `config:"host"`
+			Port int    `config:"port"`
+		}
+		type config struct {
+			Upstreams []upstream          `config:"upstreams"`
+			Clusters  map[string]upstream `config:"clusters"`
+		}
+
+		path, cleanup := createTempFile(t, "config.yaml", `
+upstreams:
+  - host: a
+    port: 80
+  - host: b
+clusters:
+  primary:
+    host: c
+`)
+		defer cleanup()
+
+		testLoad(t, path, &config{}, &config{
+			Upstreams: []upstream{{Host: "a", Port: 80}, {Host: "b"}},
+			Clusters:  map[string]upstream{"primary": {Host: "c"}},
+		})
+	})
+
+	t.Run("HCL blocks", func(t *testing.T) {
+		type upstream struct {
+			Host string `config:"host"`
+			Port int    `config:"port"`
+		}
+		type config struct {
+			Upstreams []upstream `config:"upstreams"`
+		}
+
+		t.Run("Single", func(t *testing.T) {
+			path, cleanup := createTempFile(t, "config.hcl", `
+upstreams {
+  host = "a"
+  port = 80
+}
+`)
+			defer cleanup()
+
+			testLoad(t, path, &config{}, &config{
+				Upstreams: []upstream{{Host: "a", Port: 80}},
+			})
+		})
+
+		t.Run("Multiple", func(t *testing.T) {
+			path, cleanup := createTempFile(t, "config.hcl", `
+upstreams {
+  host = "a"
+  port = 80
+}
+upstreams {
+  host = "b"
+}
+`)
+			defer cleanup()
+
+			testLoad(t, path, &config{}, &config{
+				Upstreams: []upstream{{Host: "a", Port: 80}, {Host: "b"}},
+			})
+		})
+	})
+
+	t.Run("Config keys", func(t *testing.T) {
+		type config struct {
+			URI      string    `config:"database-uri" json:"uri" yaml:"uri"`
+			Hosts    []string  `config:"hosts"`
+			Ports    []int     `config:"ports"`
+			Enabled  bool      `config:"enabled"`
+			Ratio    float64   `config:"ratio"`
+			Since    time.Time `config:"since"`
+			Nested   string    `config:"database.options.mode"`
+			Missing  string    `config:"missing"`
+			Null     string    `config:"null"`
+			Untagged string
+		}
+
+		e := config{
+			URI:      "postgres://db",
+			Hosts:    []string{"a,b", "c"},
+			Ports:    []int{80, 443},
+			Enabled:  true,
+			Ratio:    0.5,
+			Since:    time.Date(2019, 3, 4, 10, 11, 12, 0, time.UTC),
+			Nested:   "rw",
+			Missing:  "default",
+			Untagged: "untouched",
+		}
+
+		files := map[string]string{
+			"config.json": `{
+				"database-uri": "postgres://db",
+				"hosts": ["a,b", "c"],
+				"ports": [80, 443],
+				"enabled": true,
+				"ratio": 0.5,
+				"since": "2019-03-04T10:11:12Z",
+				"database": {"options": {"mode": "rw"}},
+				"null": null,
+				"Untagged": "changed"
+			}`,
+			"config.yaml": `
+database-uri: postgres://db
+hosts: ["a,b", c]
+ports: [80, 443]
+enabled: true
+ratio: 0.5
+since: 2019-03-04T10:11:12Z
+database:
+  options:
+    mode: rw
+null:
+Untagged: changed
+`,
+			"config.toml": `
+database-uri = "postgres://db"
+hosts = ["a,b", "c"]
+ports = [80, 443]
+enabled = true
+ratio = 0.5
+since = 2019-03-04T10:11:12Z
+Untagged = "changed"
+
+[database.options]
+mode = "rw"
+`,
+		}
+
+		for name, content := range files {
+			t.Run(name, func(t *testing.T) {
+				path, cleanup := createTempFile(t, name, content)
+				defer cleanup()
+
+				testLoad(t, path, &config{Missing: "default", Untagged: "untouched"}, &e)
+			})
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		path, cleanup := createTempFile(t, "config.yaml", `
+name: from file
+age: 10
+`)
+		defer cleanup()
+
+		st := store{
+			"name": "from store",
+		}
+
+		var c config
+		err := confita.NewLoader(st, file.NewBackend(path)).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "from store", Age: 10}, c)
+	})
+
+	t.Run("Backend option", func(t *testing.T) {
+		path, cleanup := createTempFile(t, "config.yaml", `
+name: from file
+age: 10
+`)
+		defer cleanup()
+
+		st := store{
+			"name": "from store",
+			"age":  "20",
+		}
+
+		c := struct {
+			Name string `config:"name,backend=yaml"`
+			Age  int    `config:"age"`
+		}{}
+		err := confita.NewLoader(st, file.NewBackend(path)).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, "from file", c.Name)
+		require.Equal(t, 20, c.Age)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		path, cleanup := createTempFile(t, "config.json", `{
+			"age": "ten"
+		}`)
+		defer cleanup()
+
+		var c config
+		err := confita.NewLoader(file.NewBackend(path)).Load(context.Background(), &c)
+		require.Error(t, err)
 	})
 
 	t.Run("Unsupported extension", func(t *testing.T) {
@@ -144,7 +367,7 @@ timeout = 11
 		var c config
 		b := file.NewBackend(path)
 
-		err := b.Unmarshal(context.Background(), &c)
+		err := confita.NewLoader(b).Load(context.Background(), &c)
 		require.Error(t, err)
 	})
 
@@ -152,15 +375,1068 @@ timeout = 11
 		var c config
 		b := file.NewBackend("some path")
 
-		err := b.Unmarshal(context.Background(), &c)
+		err := confita.NewLoader(b).Load(context.Background(), &c)
 		require.Error(t, err)
 	})
 
 	t.Run("Optional file not found", func(t *testing.T) {
-		var c config
+		c := config{Name: "default"}
 		b := file.NewOptionalBackend("some path")
 
-		err := b.Unmarshal(context.Background(), &c)
-		require.EqualError(t, err, backend.ErrNotFound.Error())
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "default"}, c)
+
+		_, err = b.Get(context.Background(), "name")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+}
+
+func TestFileBackendGet(t *testing.T) {
+	path, cleanup := createTempFile(t, "config.json", `{
+		"name": "some name",
+		"database.port": 5432,
+		"database": {
+			"uri": "postgres://db",
+			"replicas": ["a", "b"]
+		}
+	}`)
+	defer cleanup()
+
+	b := file.NewBackend(path)
+
+	tests := map[string]string{
+		"name":                "some name",
+		"database.port":       "5432",
+		"database.uri":        "postgres://db",
+		"database.replicas":   `["a","b"]`,
+		"database.replicas.1": "b",
+	}
+
+	for key, expected := range tests {
+		val, err := b.Get(context.Background(), key)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(val))
+	}
+
+	_, err := b.Get(context.Background(), "database.user")
+	require.Equal(t, backend.ErrNotFound, err)
+
+	// the file is only read once
+	require.NoError(t, os.Remove(path))
+	val, err := b.Get(context.Background(), "database.uri")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://db", string(val))
+
+	keys, err := b.List(context.Background(), "database.")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"database.port", "database.uri", "database.replicas.0", "database.replicas.1"}, keys)
+}
+
+func TestFileFormats(t *testing.T) {
+	type config struct {
+		Name    string        `config:"name"`
+		Age     int           `config:"age"`
+		Timeout time.Duration `config:"timeout"`
+		URI     string        `config:"database.uri"`
+		Hosts   []string      `config:"database.hosts"`
+	}
+
+	e := config{
+		Name:    "some name",
+		Age:     10,
+		Timeout: 10 * time.Second,
+		URI:     "postgres://db?a=b",
+		Hosts:   []string{"a", "b"},
+	}
+
+	files := map[string]string{
+		"config.hcl": `
+name = "some name"
+age = 10
+timeout = "10s"
+
+database {
+  uri = "postgres://db?a=b"
+  hosts = ["a", "b"]
+}
+`,
+		"config.ini": `
+; global settings
+name = "some name"
+age = 10
+timeout: 10s
+
+[database]
+# connection
+uri = postgres://db?a=b
+hosts = a,b
+`,
+		"config.properties": `
+# global settings
+name = some name
+age : 10
+timeout 10s
+! connection
+database.uri = postgres://db?a\=b
+database.hosts = a,\
+                 b
+`,
+		"config.jsonc": `{
+	// global settings
+	"name": "some name", /* inline */
+	"age": 10,
+	"timeout": "10s",
+	"database": {
+		"uri": "postgres://db?a=b", // "not a string"
+		"hosts": ["a", "b",],
+	},
+}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path, cleanup := createTempFile(t, name, content)
+			defer cleanup()
+
+			var c config
+			err := confita.NewLoader(file.NewBackend(path)).Load(context.Background(), &c)
+			require.NoError(t, err)
+			require.Equal(t, e, c)
+		})
+	}
+
+	t.Run("Properties escapes", func(t *testing.T) {
+		path, cleanup := createTempFile(t, "config.properties", `key\ with\ spaces=tab\there
+unicode=caf\u00e9`)
+		defer cleanup()
+
+		b := file.NewBackend(path)
+
+		val, err := b.Get(context.Background(), "key with spaces")
+		require.NoError(t, err)
+		require.Equal(t, "tab\there", string(val))
+
+		val, err = b.Get(context.Background(), "unicode")
+		require.NoError(t, err)
+		require.Equal(t, "café", string(val))
+	})
+
+	t.Run("RegisterFormat", func(t *testing.T) {
+		file.RegisterFormat("lines", func(r io.Reader) (map[string]any, error) {
+			doc := make(map[string]any)
+			s := bufio.NewScanner(r)
+			for s.Scan() {
+				k, v, _ := strings.Cut(s.Text(), " ")
+				doc[k] = v
+			}
+			return doc, s.Err()
+		})
+
+		path, cleanup := createTempFile(t, "config.lines", "name some name\nage 10\n")
+		defer cleanup()
+
+		var c config
+		err := confita.NewLoader(file.NewBackend(path)).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "some name", Age: 10}, c)
+	})
+}
+
+func TestFileBackendSources(t *testing.T) {
+	type config struct {
+		Name string `config:"name"`
+		Age  int    `config:"age"`
+	}
+
+	fsys := fstest.MapFS{
+		"conf/base.yaml": &fstest.MapFile{Data: []byte("name: from fs\nage: 10\n")},
+	}
+
+	t.Run("FS", func(t *testing.T) {
+		b := file.NewFSBackend(fsys, "conf/base.yaml")
+		require.Equal(t, "yaml", b.Name())
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "from fs", Age: 10}, c)
+	})
+
+	t.Run("FS file not found", func(t *testing.T) {
+		var c config
+		err := confita.NewLoader(file.NewFSBackend(fsys, "conf/other.yaml")).Load(context.Background(), &c)
+		require.Error(t, err)
+
+		err = confita.NewLoader(file.NewOptionalFSBackend(fsys, "conf/other.yaml")).Load(context.Background(), &c)
+		require.NoError(t, err)
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		b := file.NewReaderBackend(strings.NewReader(`{"name": "from reader"}`), "json")
+		require.Equal(t, "json", b.Name())
+
+		c := config{Age: 10}
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "from reader", Age: 10}, c)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		b := file.NewBytesBackend([]byte("name = \"from bytes\"\nage = 10\n"), ".toml")
+		require.Equal(t, "toml", b.Name())
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "from bytes", Age: 10}, c)
+	})
+
+	t.Run("Bad content", func(t *testing.T) {
+		var c config
+		err := confita.NewLoader(file.NewBytesBackend([]byte("{"), "json")).Load(context.Background(), &c)
+		require.EqualError(t, err, "failed to decode json content: unexpected EOF")
+
+		// the error is reported every time, even though the reader was consumed.
+		l := confita.NewLoader(file.NewReaderBackend(strings.NewReader("{"), "json"))
+		for i := 0; i < 2; i++ {
+			err = l.Load(context.Background(), &c)
+			require.EqualError(t, err, "failed to decode json content: unexpected EOF")
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		override := file.NewBytesBackend([]byte("age: 20\n"), "yaml")
+
+		var c config
+		err := confita.NewLoader(override, file.NewFSBackend(fsys, "conf/base.yaml")).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "from fs", Age: 20}, c)
+	})
+}
+
+func TestDirBackend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "confita")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"10-base.yaml": `
+name: base
+database:
+  uri: postgres://base
+  pool: 10
+hosts: [a, b]
+`,
+		"20-override.yaml": `
+database:
+  uri: postgres://override
+hosts: [c]
+`,
+		"30-local.yaml": `
+name: local
+`,
+		"README.md": `not a config file`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "99-dir.yaml"), 0700))
+
+	type config struct {
+		Name  string   `config:"name"`
+		URI   string   `config:"database.uri"`
+		Pool  int      `config:"database.pool"`
+		Hosts []string `config:"hosts"`
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		b := file.NewDirBackend(dir, "*.yaml")
+		require.Equal(t, "yaml", b.Name())
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{
+			Name:  "local",
+			URI:   "postgres://override",
+			Pool:  10,
+			Hosts: []string{"c"},
+		}, c)
 	})
+
+	t.Run("AppendLists", func(t *testing.T) {
+		var c config
+		err := confita.NewLoader(file.NewDirBackend(dir, "*.yaml", file.WithAppendLists())).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b", "c"}, c.Hosts)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		var conflicts []file.Conflict
+		b := file.NewDirBackend(dir, "*.yaml", file.WithConflictHandler(func(c file.Conflict) error {
+			conflicts = append(conflicts, c)
+			return nil
+		}))
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, []file.Conflict{
+			{Key: "database.uri", Previous: filepath.Join(dir, "10-base.yaml"), File: filepath.Join(dir, "20-override.yaml")},
+			{Key: "hosts", Previous: filepath.Join(dir, "10-base.yaml"), File: filepath.Join(dir, "20-override.yaml")},
+			{Key: "name", Previous: filepath.Join(dir, "10-base.yaml"), File: filepath.Join(dir, "30-local.yaml")},
+		}, conflicts)
+	})
+
+	t.Run("ConflictError", func(t *testing.T) {
+		b := file.NewDirBackend(dir, "*.yaml", file.WithConflictHandler(func(c file.Conflict) error {
+			return fmt.Errorf("%s redefined in %s", c.Key, filepath.Base(c.File))
+		}))
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.EqualError(t, err, "database.uri redefined in 20-override.yaml")
+	})
+
+	t.Run("MissingDir", func(t *testing.T) {
+		c := config{Name: "default"}
+		err := confita.NewLoader(file.NewDirBackend(filepath.Join(dir, "missing"), "*.yaml")).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "default"}, c)
+	})
+
+	t.Run("InvalidFile", func(t *testing.T) {
+		bad, err := os.MkdirTemp("", "confita")
+		require.NoError(t, err)
+		defer os.RemoveAll(bad)
+		require.NoError(t, os.WriteFile(filepath.Join(bad, "bad.json"), []byte("{"), 0600))
+
+		var c config
+		err = confita.NewLoader(file.NewDirBackend(bad, "*.json")).Load(context.Background(), &c)
+		require.Error(t, err)
+	})
+}
+
+func TestSearchBackend(t *testing.T) {
+	root, err := os.MkdirTemp("", "confita")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{"local", "home/.app", "etc/app", "empty", "dir/app.yaml"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0700))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home/.app/app.yaml"), []byte("name: home\nage: 10\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc/app/app.yaml"), []byte("name: etc\nage: 20\nlevel: debug\n"), 0600))
+
+	t.Setenv("CONFITA_TEST_HOME", filepath.Join(root, "home"))
+	t.Setenv("CONFITA_TEST_UNSET", "")
+
+	paths := []string{
+		filepath.Join(root, "empty"),
+		filepath.Join(root, "dir"),
+		"$CONFITA_TEST_UNSET/etc/app",
+		"$CONFITA_TEST_HOME/.app",
+		filepath.Join(root, "etc/app"),
+	}
+
+	type config struct {
+		Name  string `config:"name"`
+		Age   int    `config:"age"`
+		Level string `config:"level"`
+	}
+
+	t.Run("First", func(t *testing.T) {
+		b := file.NewSearchBackend("app.yaml", paths...)
+		require.Equal(t, "yaml", b.Name())
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "home", Age: 10}, c)
+		require.Equal(t, []string{filepath.Join(root, "home/.app/app.yaml")}, b.Files())
+	})
+
+	t.Run("All", func(t *testing.T) {
+		b := file.NewSearchAllBackend("app.yaml", paths...)
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "home", Age: 10, Level: "debug"}, c)
+		require.Equal(t, []string{
+			filepath.Join(root, "home/.app/app.yaml"),
+			filepath.Join(root, "etc/app/app.yaml"),
+		}, b.Files())
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "local/app.yaml"), []byte("name: ${NAME}\n"), 0600))
+		defer os.Remove(filepath.Join(root, "local/app.yaml"))
+
+		lookup := func(name string) (string, bool) {
+			return "expanded", name == "NAME"
+		}
+		b := file.NewSearchAllBackendWithOptions("app.yaml", append([]string{filepath.Join(root, "local")}, paths...), file.WithEnvExpansion(lookup))
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "expanded", Age: 10, Level: "debug"}, c)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		b := file.NewSearchBackend("app.yaml", filepath.Join(root, "empty"))
+
+		c := config{Name: "default"}
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "default"}, c)
+		require.Empty(t, b.Files())
+
+		_, err = b.Get(context.Background(), "name")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("DefaultSearchPaths", func(t *testing.T) {
+		require.Equal(t, []string{
+			".",
+			"$XDG_CONFIG_HOME/app",
+			"$HOME/.config/app",
+			"$HOME/.app",
+			"/etc/app",
+		}, file.DefaultSearchPaths("app"))
+	})
+}
+
+func TestEnvExpansion(t *testing.T) {
+	env := map[string]string{
+		"HOSTNAME": "host-1",
+		"EMPTY":    "",
+		"REGION":   "eu",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	type config struct {
+		Host     string `config:"host"`
+		Port     int    `config:"port"`
+		Name     string `config:"name"`
+		Empty    string `config:"empty"`
+		Unset    string `config:"unset"`
+		Price    string `config:"price"`
+		Endpoint string `config:"endpoint"`
+	}
+
+	content := `
+host: ${HOSTNAME}
+port: ${PORT:-8080}
+name: $HOSTNAME-$REGION
+empty: ${EMPTY-unused}
+unset: ${UNSET}
+price: $$10
+endpoint: ${ENDPOINT:-https://${REGION}.example.com}
+`
+
+	t.Run("OK", func(t *testing.T) {
+		b := file.NewBytesBackend([]byte(content), "yaml", file.WithEnvExpansion(lookup))
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{
+			Host:     "host-1",
+			Port:     8080,
+			Name:     "host-1-eu",
+			Price:    "$10",
+			Endpoint: "https://eu.example.com",
+		}, c)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		b := file.NewBytesBackend([]byte(content), "yaml")
+
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		require.Error(t, err)
+
+		val, err := b.Get(context.Background(), "host")
+		require.NoError(t, err)
+		require.Equal(t, "${HOSTNAME}", string(val))
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		tests := map[string]string{
+			"password: ${PASSWORD:?password must be set}": "failed to decode yaml content: variable PASSWORD: password must be set",
+			"password: ${EMPTY:?}":                        "failed to decode yaml content: variable EMPTY: not set",
+			"password: ${PASSWORD":                        `failed to decode yaml content: missing closing brace in "${PASSWORD"`,
+			"password: ${PASSWORD:}":                      `failed to decode yaml content: invalid expression "${PASSWORD:}"`,
+		}
+
+		for content, msg := range tests {
+			b := file.NewBytesBackend([]byte(content), "yaml", file.WithEnvExpansion(lookup))
+			_, err := b.Get(context.Background(), "password")
+			require.EqualError(t, err, msg)
+		}
+
+		b := file.NewBytesBackend([]byte("password: ${EMPTY?}"), "yaml", file.WithEnvExpansion(lookup))
+		_, err := b.Get(context.Background(), "password")
+		require.Equal(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("Process environment", func(t *testing.T) {
+		t.Setenv("CONFITA_TEST_HOST", "from-env")
+
+		path, cleanup := createTempFile(t, "config.toml", `host = "${CONFITA_TEST_HOST}"`)
+		defer cleanup()
+
+		var c config
+		err := confita.NewLoader(file.NewBackend(path, file.WithEnvExpansion(nil))).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, "from-env", c.Host)
+	})
+}
+
+func TestIncludes(t *testing.T) {
+	dir, err := os.MkdirTemp("", "confita")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"shared/base.yaml": `
+name: base
+age: 10
+database:
+  uri: postgres://base
+  pool: 5
+`,
+		"shared/secrets.json": `{"database": {"password": "secret"}}`,
+		"prod.yaml": `
+include: [shared/base.yaml, shared/secrets.json]
+name: prod
+database:
+  uri: postgres://prod
+`,
+		"single.toml": `
+include = "shared/base.yaml"
+age = 20
+`,
+		"nested.yaml": `
+include: prod.yaml
+age: 30
+`,
+		"cycle-a.yaml": "include: cycle-b.yaml\n",
+		"cycle-b.yaml": "include: [cycle-a.yaml]\n",
+		"missing.yaml": "include: nope.yaml\n",
+		"invalid.yaml": "include: {a: b}\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	type config struct {
+		Name     string `config:"name"`
+		Age      int    `config:"age"`
+		URI      string `config:"database.uri"`
+		Pool     int    `config:"database.pool"`
+		Password string `config:"database.password"`
+		Include  string `config:"include"`
+	}
+
+	load := func(path string) (config, *file.Backend, error) {
+		var c config
+		b := file.NewBackend(filepath.Join(dir, path), file.WithIncludeKey("include"))
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		return c, b, err
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		c, b, err := load("prod.yaml")
+		require.NoError(t, err)
+		require.Equal(t, config{
+			Name:     "prod",
+			Age:      10,
+			URI:      "postgres://prod",
+			Pool:     5,
+			Password: "secret",
+		}, c)
+		require.Equal(t, []string{
+			filepath.Join(dir, "prod.yaml"),
+			filepath.Join(dir, "shared/secrets.json"),
+			filepath.Join(dir, "shared/base.yaml"),
+		}, b.Files())
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		c, _, err := load("single.toml")
+		require.NoError(t, err)
+		require.Equal(t, "base", c.Name)
+		require.Equal(t, 20, c.Age)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		c, _, err := load("nested.yaml")
+		require.NoError(t, err)
+		require.Equal(t, "prod", c.Name)
+		require.Equal(t, 30, c.Age)
+		require.Equal(t, "secret", c.Password)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, _, err := load("cycle-a.yaml")
+		require.EqualError(t, err, fmt.Sprintf("include cycle: %[1]s/cycle-a.yaml -> %[1]s/cycle-b.yaml -> %[1]s/cycle-a.yaml", dir))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, _, err := load("missing.yaml")
+		require.Error(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := load("invalid.yaml")
+		require.Error(t, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var c config
+		err := confita.NewLoader(file.NewBackend(filepath.Join(dir, "single.toml"))).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Age: 20, Include: "shared/base.yaml"}, c)
+	})
+
+	t.Run("FS", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"conf/app.yaml":  &fstest.MapFile{Data: []byte("include: base.yaml\nname: app\n")},
+			"conf/base.yaml": &fstest.MapFile{Data: []byte("name: base\nage: 10\n")},
+		}
+
+		var c config
+		err := confita.NewLoader(file.NewFSBackend(fsys, "conf/app.yaml", file.WithIncludeKey("include"))).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "app", Age: 10}, c)
+	})
+}
+
+func TestEncryption(t *testing.T) {
+	dir, err := os.MkdirTemp("", "confita")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	aes, err := file.NewAESGCM(key)
+	require.NoError(t, err)
+
+	type config struct {
+		Name     string   `config:"name"`
+		Password string   `config:"database.password"`
+		Port     int      `config:"database.port"`
+		Tokens   []string `config:"tokens"`
+	}
+
+	t.Run("Values", func(t *testing.T) {
+		password, err := aes.EncryptValue("secret")
+		require.NoError(t, err)
+		port, err := aes.EncryptValue("5432")
+		require.NoError(t, err)
+		token, err := aes.EncryptValue("b")
+		require.NoError(t, err)
+
+		path := filepath.Join(dir, "values.yaml")
+		content := fmt.Sprintf("name: ENC\ndatabase:\n  password: %s\n  port: %s\ntokens:\n  - a\n  - %s\n", password, port, token)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+		var c config
+		err = confita.NewLoader(file.NewBackend(path, file.WithEncryptedValues(aes))).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "ENC", Password: "secret", Port: 5432, Tokens: []string{"a", "b"}}, c)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		ciphertext, err := aes.Encrypt([]byte("name: app\ndatabase:\n  password: secret\n"))
+		require.NoError(t, err)
+
+		raw := filepath.Join(dir, "config.yaml.enc")
+		require.NoError(t, os.WriteFile(raw, ciphertext, 0600))
+		encoded := filepath.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(encoded, []byte(base64.StdEncoding.EncodeToString(ciphertext)+"\n"), 0600))
+
+		for _, path := range []string{raw, encoded} {
+			var c config
+			err = confita.NewLoader(file.NewBackend(path, file.WithDecryptor(aes))).Load(context.Background(), &c)
+			require.NoError(t, err)
+			require.Equal(t, config{Name: "app", Password: "secret"}, c)
+		}
+	})
+
+	t.Run("KeyFromEnv", func(t *testing.T) {
+		os.Setenv("CONFITA_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+		defer os.Unsetenv("CONFITA_TEST_KEY")
+
+		d, err := file.NewAESGCMFromEnv("CONFITA_TEST_KEY")
+		require.NoError(t, err)
+
+		value, err := aes.EncryptValue("secret")
+		require.NoError(t, err)
+
+		var c config
+		err = confita.NewLoader(file.NewBytesBackend([]byte("name: "+value), "yaml", file.WithEncryptedValues(d))).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, "secret", c.Name)
+
+		_, err = file.NewAESGCMFromEnv("CONFITA_TEST_MISSING_KEY")
+		require.Error(t, err)
+	})
+
+	t.Run("KeyFromFile", func(t *testing.T) {
+		path := filepath.Join(dir, "key")
+		require.NoError(t, os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+
+		d, err := file.NewAESGCMFromFile(path)
+		require.NoError(t, err)
+
+		ciphertext, err := d.Encrypt([]byte("secret"))
+		require.NoError(t, err)
+		plaintext, err := aes.Decrypt(ciphertext)
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(plaintext))
+
+		_, err = file.NewAESGCMFromFile(filepath.Join(dir, "missing"))
+		require.Error(t, err)
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		other, err := file.NewAESGCM([]byte("fedcba9876543210"))
+		require.NoError(t, err)
+
+		value, err := aes.EncryptValue("secret")
+		require.NoError(t, err)
+
+		var c config
+		err = confita.NewLoader(file.NewBytesBackend([]byte("name: "+value), "yaml", file.WithEncryptedValues(other))).Load(context.Background(), &c)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to decrypt value for key \"name\"")
+	})
+
+	t.Run("Func", func(t *testing.T) {
+		rot := file.DecryptorFunc(func(ciphertext []byte) ([]byte, error) {
+			if string(ciphertext) != "terces" {
+				return nil, errors.New("unexpected ciphertext")
+			}
+			return []byte("secret"), nil
+		})
+
+		value := "ENC[" + base64.StdEncoding.EncodeToString([]byte("terces")) + "]"
+
+		var c config
+		err = confita.NewLoader(file.NewBytesBackend([]byte(`{"name": "`+value+`"}`), "json", file.WithEncryptedValues(rot))).Load(context.Background(), &c)
+		require.NoError(t, err)
+		require.Equal(t, "secret", c.Name)
+	})
+}
+
+func TestWatch(t *testing.T) {
+	type config struct {
+		Name string `config:"name"`
+	}
+
+	// receive waits for a notification and loads the configuration again.
+	receive := func(t *testing.T, ch <-chan struct{}, b *file.Backend) string {
+		t.Helper()
+
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a change")
+		}
+
+		var c config
+		require.NoError(t, confita.NewLoader(b).Load(context.Background(), &c))
+		return c.Name
+	}
+
+	noChange := func(t *testing.T, ch <-chan struct{}) {
+		t.Helper()
+
+		select {
+		case <-ch:
+			t.Fatal("unexpected change")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	// watch loads the configuration from b and starts watching it.
+	watch := func(t *testing.T, b *file.Backend) (<-chan struct{}, string) {
+		t.Helper()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		var c config
+		require.NoError(t, confita.NewLoader(b).Load(ctx, &c))
+
+		ch, err := b.Watch(ctx)
+		require.NoError(t, err)
+		return ch, c.Name
+	}
+
+	for _, mode := range []string{"Notify", "Polling"} {
+		opts := []file.Option{file.WithDebounce(20 * time.Millisecond)}
+		if mode == "Polling" {
+			opts = append(opts, file.WithPolling(10*time.Millisecond))
+		}
+
+		t.Run(mode, func(t *testing.T) {
+			t.Run("Write", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				require.NoError(t, os.WriteFile(path, []byte("name: a"), 0600))
+
+				b := file.NewBackend(path, opts...)
+				ch, name := watch(t, b)
+				require.Equal(t, "a", name)
+
+				// bursts of writes result in a single notification
+				for _, content := range []string{"name: ", "name: b"} {
+					require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+				}
+				require.Equal(t, "b", receive(t, ch, b))
+				noChange(t, ch)
+
+				// the content didn't change
+				now := time.Now().Add(time.Minute)
+				require.NoError(t, os.Chtimes(path, now, now))
+				noChange(t, ch)
+			})
+
+			t.Run("AtomicRename", func(t *testing.T) {
+				dir := t.TempDir()
+				path := filepath.Join(dir, "config.yaml")
+				require.NoError(t, os.WriteFile(path, []byte("name: a"), 0600))
+
+				b := file.NewBackend(path, opts...)
+				ch, _ := watch(t, b)
+
+				for _, name := range []string{"b", "c"} {
+					tmp := filepath.Join(dir, ".config.yaml.swp")
+					require.NoError(t, os.WriteFile(tmp, []byte("name: "+name), 0600))
+					require.NoError(t, os.Rename(tmp, path))
+					require.Equal(t, name, receive(t, ch, b))
+				}
+			})
+
+			t.Run("ConfigMap", func(t *testing.T) {
+				// reproduce the layout of the volumes mounted from Kubernetes ConfigMaps
+				dir := t.TempDir()
+				update := func(version, name string) {
+					require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0700))
+					require.NoError(t, os.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte("name: "+name), 0600))
+					require.NoError(t, os.Symlink(version, filepath.Join(dir, "..data_tmp")))
+					require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+				}
+				update("..2024_01_01", "a")
+				require.NoError(t, os.Symlink("..data/config.yaml", filepath.Join(dir, "config.yaml")))
+
+				b := file.NewBackend(filepath.Join(dir, "config.yaml"), opts...)
+				ch, name := watch(t, b)
+				require.Equal(t, "a", name)
+
+				update("..2024_01_02", "b")
+				require.NoError(t, os.RemoveAll(filepath.Join(dir, "..2024_01_01")))
+				require.Equal(t, "b", receive(t, ch, b))
+			})
+
+			t.Run("Dir", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "00-base.yaml"), []byte("name: a"), 0600))
+
+				b := file.NewDirBackend(dir, "*.yaml", opts...)
+				ch, _ := watch(t, b)
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "10-override.yaml"), []byte("name: b"), 0600))
+				require.Equal(t, "b", receive(t, ch, b))
+
+				require.NoError(t, os.Remove(filepath.Join(dir, "10-override.yaml")))
+				require.Equal(t, "a", receive(t, ch, b))
+			})
+
+			t.Run("Includes", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, os.Mkdir(filepath.Join(dir, "shared"), 0700))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "shared", "base.yaml"), []byte("name: a"), 0600))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("include: shared/base.yaml"), 0600))
+
+				b := file.NewBackend(filepath.Join(dir, "config.yaml"), append(opts, file.WithIncludeKey("include"))...)
+				ch, name := watch(t, b)
+				require.Equal(t, "a", name)
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "shared", "base.yaml"), []byte("name: b"), 0600))
+				require.Equal(t, "b", receive(t, ch, b))
+			})
+		})
+	}
+
+	t.Run("Bytes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		ch, err := file.NewBytesBackend([]byte("name: a"), "yaml").Watch(ctx)
+		require.NoError(t, err)
+
+		cancel()
+		_, ok := <-ch
+		require.False(t, ok)
+	})
+}
+
+func TestHTTPBackend(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		files    = make(map[string]string)
+		modified int // number of responses sending a body
+	)
+	setFile := func(path, content string) {
+		mu.Lock()
+		defer mu.Unlock()
+		files[path] = content
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		content, ok := files[r.URL.Path]
+		mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(content)))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		mu.Lock()
+		modified++
+		mu.Unlock()
+
+		if r.URL.Query().Get("type") != "" {
+			w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, content)
+	}))
+	defer srv.Close()
+
+	type config struct {
+		Name string `config:"name"`
+		Age  int    `config:"age"`
+	}
+
+	auth := file.WithHeader("Authorization", "Bearer token")
+
+	load := func(b *file.Backend) (config, error) {
+		var c config
+		err := confita.NewLoader(b).Load(context.Background(), &c)
+		return c, err
+	}
+
+	t.Run("Extension", func(t *testing.T) {
+		setFile("/config.json", `{"name": "json", "age": 10}`)
+
+		b := file.NewHTTPBackend(srv.URL+"/config.json?signature=abc", auth)
+		c, err := load(b)
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "json", Age: 10}, c)
+		require.Equal(t, "json", b.Name())
+		require.Equal(t, []string{srv.URL + "/config.json?signature=abc"}, b.Files())
+	})
+
+	t.Run("ContentType", func(t *testing.T) {
+		setFile("/config", "name: yaml\nage: 20\n")
+
+		c, err := load(file.NewHTTPBackend(srv.URL+"/config?type="+url.QueryEscape("application/x-yaml; charset=utf-8"), auth))
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "yaml", Age: 20}, c)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		_, err := load(file.NewHTTPBackend(srv.URL + "/config.json"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "401 Unauthorized")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := file.NewHTTPBackend(srv.URL+"/missing.json", auth).Get(context.Background(), "name")
+		require.Error(t, err)
+		require.NotEqual(t, backend.ErrNotFound, err)
+	})
+
+	t.Run("Includes", func(t *testing.T) {
+		setFile("/shared/base.toml", "name = \"base\"\nage = 30\n")
+		setFile("/app/config.yaml", "include: ../shared/base.toml\nname: app\n")
+
+		c, err := load(file.NewHTTPBackend(srv.URL+"/app/config.yaml", auth, file.WithIncludeKey("include")))
+		require.NoError(t, err)
+		require.Equal(t, config{Name: "app", Age: 30}, c)
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		setFile("/watch.json", `{"name": "a"}`)
+
+		b := file.NewHTTPBackend(srv.URL+"/watch.json", auth, file.WithPolling(10*time.Millisecond))
+		c, err := load(b)
+		require.NoError(t, err)
+		require.Equal(t, "a", c.Name)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, err := b.Watch(ctx)
+		require.NoError(t, err)
+
+		mu.Lock()
+		before := modified
+		mu.Unlock()
+
+		// unchanged files are not downloaded again
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		require.Equal(t, before, modified)
+		mu.Unlock()
+
+		setFile("/watch.json", `{"name": "b"}`)
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a change")
+		}
+
+		c, err = load(b)
+		require.NoError(t, err)
+		require.Equal(t, "b", c.Name)
+
+		mu.Lock()
+		require.Equal(t, before+1, modified)
+		mu.Unlock()
+	})
+}
+
+type store map[string]string
+
+func (s store) Get(ctx context.Context, key string) ([]byte, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, backend.ErrNotFound
+	}
+
+	return []byte(data), nil
+}
+
+func (store) Name() string {
+	return "store"
 }
@@ -0,0 +1,343 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// A Decoder parses the content of a file into a tree made of
+// map[string]any, []any and scalar values.
+type Decoder func(r io.Reader) (map[string]any, error)
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]Decoder{
+		".json":       decodeJSON,
+		".jsonc":      decodeJSONC,
+		".yml":        decodeYAML,
+		".yaml":       decodeYAML,
+		".toml":       decodeTOML,
+		".hcl":        decodeHCL,
+		".ini":        decodeINI,
+		".properties": decodeProperties,
+	}
+)
+
+// RegisterFormat registers the decoder used to parse files with the given extension, e.g. ".xml".
+// It replaces any decoder previously registered for that extension.
+func RegisterFormat(ext string, dec Decoder) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	formats[ext] = dec
+}
+
+func lookupFormat(ext string) (Decoder, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	dec, ok := formats[ext]
+	return dec, ok
+}
+
+func decodeJSON(r io.Reader) (map[string]any, error) {
+	var doc map[string]any
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	return doc, err
+}
+
+// decodeJSONC decodes JSON documents containing comments and trailing commas.
+func decodeJSONC(r io.Reader) (map[string]any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSON(bytes.NewReader(stripJSONC(data)))
+}
+
+// stripJSONC removes the // and /* */ comments as well as the trailing commas
+// found outside of strings.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case c == '"':
+			// copy the string, including escaped quotes
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(data) {
+				j = len(data) - 1
+			}
+			out = append(out, data[i:j+1]...)
+			i = j
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end == -1 {
+				i = len(data)
+				continue
+			}
+			i += end + 3
+			out = append(out, ' ')
+		case c == ']' || c == '}':
+			// drop the trailing comma, if any
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+func decodeYAML(r io.Reader) (map[string]any, error) {
+	var raw map[any]any
+
+	err := yaml.NewDecoder(r).Decode(&raw)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	doc, _ := normalize(raw).(map[string]any)
+	return doc, nil
+}
+
+func decodeTOML(r io.Reader) (map[string]any, error) {
+	var doc map[string]any
+
+	_, err := toml.DecodeReader(r, &doc)
+	return doc, err
+}
+
+// decodeHCL decodes HCL documents. Since HCL decodes every block as a list,
+// blocks that are only defined once are turned into objects.
+func decodeHCL(r io.Reader) (map[string]any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	err = hcl.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeBlocks(doc).(map[string]any), nil
+}
+
+// normalizeBlocks turns the lists of blocks decoded by HCL into lists of objects. They are kept as lists,
+// even if there is a single block, so that they can be loaded into slices: the fields of a single block
+// are found by lookup.
+func normalizeBlocks(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, v := range t {
+			t[k] = normalizeBlocks(v)
+		}
+		return t
+	case []map[string]any:
+		l := make([]any, len(t))
+		for i := range t {
+			l[i] = normalizeBlocks(t[i])
+		}
+		return l
+	case []any:
+		for i := range t {
+			t[i] = normalizeBlocks(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// decodeINI decodes INI documents. Keys defined before the first section
+// are stored at the root of the document, the other ones are stored in an object
+// named after their section. Both ";" and "#" start a comment line.
+func decodeINI(r io.Reader) (map[string]any, error) {
+	doc := make(map[string]any)
+	section := doc
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, errors.Errorf("line %d: invalid section", n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if m, ok := doc[name].(map[string]any); ok {
+				section = m
+				continue
+			}
+			section = make(map[string]any)
+			doc[name] = section
+			continue
+		}
+
+		idx := strings.IndexAny(line, "=:")
+		if idx == -1 {
+			return nil, errors.Errorf("line %d: missing value", n)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		section[key] = value
+	}
+
+	return doc, s.Err()
+}
+
+// decodeProperties decodes Java properties files. Keys are stored as is
+// at the root of the document, so that dotted keys can be looked up directly.
+func decodeProperties(r io.Reader) (map[string]any, error) {
+	doc := make(map[string]any)
+
+	s := bufio.NewScanner(r)
+	var logical string
+	for s.Scan() {
+		line := strings.TrimLeft(s.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		// an odd number of trailing backslashes continues the line
+		trailing := len(line) - len(strings.TrimRight(line, "\\"))
+		if trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, err
+		}
+		doc[key] = value
+		logical = ""
+	}
+	if logical != "" {
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, err
+		}
+		doc[key] = value
+	}
+
+	return doc, s.Err()
+}
+
+// splitProperty splits a property line into its unescaped key and value.
+// The key ends at the first unescaped "=", ":" or whitespace.
+func splitProperty(line string) (string, string, error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '=' || line[i] == ':' || line[i] == ' ' || line[i] == '\t' || line[i] == '\f' {
+			end = i
+			break
+		}
+	}
+
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	key, err := unescapeProperty(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", errors.Errorf("invalid unicode escape in %q", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", errors.Errorf("invalid unicode escape in %q", s)
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
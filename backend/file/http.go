@@ -0,0 +1,182 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// contentTypes maps the media types of the documents fetched by the HTTP backends to the extension
+// of their format, when the URL doesn't have one.
+var contentTypes = map[string]string{
+	"application/json":   ".json",
+	"text/json":          ".json",
+	"application/yaml":   ".yaml",
+	"application/x-yaml": ".yaml",
+	"text/yaml":          ".yaml",
+	"text/x-yaml":        ".yaml",
+	"application/toml":   ".toml",
+	"text/toml":          ".toml",
+}
+
+// WithHTTPClient sets the client used by NewHTTPBackend, e.g. to configure timeouts or TLS.
+// It defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(b *Backend) {
+		b.httpClient = c
+	}
+}
+
+// WithHeader adds a header to the requests sent by NewHTTPBackend, e.g. to authenticate them:
+//
+//	file.WithHeader("Authorization", "Bearer "+token)
+func WithHeader(key, value string) Option {
+	return func(b *Backend) {
+		if b.header == nil {
+			b.header = make(http.Header)
+		}
+		b.header.Add(key, value)
+	}
+}
+
+// remote holds the last response received by an HTTP backend.
+type remote struct {
+	mu           sync.Mutex
+	body         []byte
+	contentType  string
+	etag         string
+	lastModified string
+}
+
+// NewHTTPBackend creates a configuration loader that fetches a file from the given URL,
+// e.g. from a configuration server or an S3 presigned URL. The content is decoded based on
+// the extension of the URL path or, if it doesn't have a known one, on the Content-Type of the response.
+//
+// The response is kept along with its ETag and Last-Modified headers, which are sent back
+// using If-None-Match and If-Modified-Since when the file is fetched again, e.g. by Watch,
+// so that it's only downloaded if it changed. URLs are polled every 30 seconds by Watch,
+// or at the interval set using WithPolling.
+func NewHTTPBackend(rawURL string, opts ...Option) *Backend {
+	b := newBackend(rawURL, path.Ext(urlPath(rawURL)), nil, opts)
+	if b.name == "" {
+		b.name = "http"
+	}
+	b.remote = new(remote)
+	b.open = func(ctx context.Context) (io.ReadCloser, error) {
+		_, err := b.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		b.remote.mu.Lock()
+		defer b.remote.mu.Unlock()
+
+		return io.NopCloser(bytes.NewReader(b.remote.body)), nil
+	}
+
+	return b
+}
+
+// fetch downloads the file, unless the server reports it didn't change since last time,
+// and reports whether its content changed.
+func (b *Backend) fetch(ctx context.Context) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, b.path, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range b.header {
+		req.Header[k] = v
+	}
+
+	b.remote.mu.Lock()
+	if b.remote.body != nil {
+		if b.remote.etag != "" {
+			req.Header.Set("If-None-Match", b.remote.etag)
+		}
+		if b.remote.lastModified != "" {
+			req.Header.Set("If-Modified-Since", b.remote.lastModified)
+		}
+	}
+	b.remote.mu.Unlock()
+
+	client := b.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("unexpected status \"%s\"", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	b.remote.mu.Lock()
+	defer b.remote.mu.Unlock()
+
+	changed := b.remote.body == nil || !bytes.Equal(b.remote.body, body)
+	b.remote.body = body
+	b.remote.contentType = resp.Header.Get("Content-Type")
+	b.remote.etag = resp.Header.Get("ETag")
+	b.remote.lastModified = resp.Header.Get("Last-Modified")
+	return changed, nil
+}
+
+// contentTypeExt returns the extension corresponding to the Content-Type of the last response.
+func (b *Backend) contentTypeExt() (string, bool) {
+	b.remote.mu.Lock()
+	defer b.remote.mu.Unlock()
+
+	mediaType, _, err := mime.ParseMediaType(b.remote.contentType)
+	if err != nil {
+		return "", false
+	}
+
+	ext, ok := contentTypes[mediaType]
+	return ext, ok
+}
+
+// urlPath returns the path of the given URL, without its query.
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return u.Path
+}
+
+// resolveURL resolves the URL of an included file from the URL of the including file.
+func (b *Backend) resolveURL(p string) string {
+	base, err := url.Parse(b.path)
+	if err != nil {
+		return p
+	}
+
+	ref, err := url.Parse(p)
+	if err != nil {
+		return p
+	}
+
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,131 @@
+package file
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithIncludeKey allows files to include other files, listed under the given top-level key
+// either as a single path or as a list of paths, e.g.
+//
+//	include: [base.yaml, secrets.yaml]
+//
+// Relative paths are resolved from the directory of the including file.
+// Included files are merged as NewDirBackend does, in order, and the values defined
+// in the including file take precedence over the included ones. Files can include
+// other files as long as they don't form a cycle.
+func WithIncludeKey(key string) Option {
+	return func(b *Backend) {
+		b.includeKey = key
+	}
+}
+
+func (b *Backend) resolveIncludes(ctx context.Context, doc map[string]any, stack []string) (map[string]any, error) {
+	v, ok := doc[b.includeKey]
+	if !ok {
+		return doc, nil
+	}
+	delete(doc, b.includeKey)
+
+	paths, err := includePaths(v)
+	if err != nil {
+		return nil, b.decodeError(errors.Wrapf(err, "invalid \"%s\" key", b.includeKey))
+	}
+
+	stack = append(stack[:len(stack):len(stack)], b.id(b.path))
+
+	m := merger{
+		doc:         make(map[string]any),
+		origins:     make(map[string]string),
+		appendLists: b.appendLists,
+		onConflict:  b.onConflict,
+	}
+
+	var included []string
+	for _, p := range paths {
+		p = b.resolvePath(p)
+
+		for i, id := range stack {
+			if id == b.id(p) {
+				return nil, errors.Errorf("include cycle: %s -> %s", strings.Join(stack[i:], " -> "), id)
+			}
+		}
+
+		fb := b.fileBackend(p)
+		idoc, err := fb.loadIncluding(ctx, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.merge(m.doc, idoc, "", p)
+		if err != nil {
+			return nil, err
+		}
+		included = append(fb.files, included...)
+	}
+
+	err = m.merge(m.doc, doc, "", b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	b.files = append(b.files, included...)
+	return m.doc, nil
+}
+
+// resolvePath resolves the path of an included file from the directory of the including file.
+func (b *Backend) resolvePath(p string) string {
+	if b.remote != nil {
+		return b.resolveURL(p)
+	}
+
+	if b.fsys != nil {
+		if path.IsAbs(p) || b.path == "" {
+			return path.Clean(strings.TrimPrefix(p, "/"))
+		}
+		return path.Join(path.Dir(b.path), p)
+	}
+
+	if filepath.IsAbs(p) || b.path == "" {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(filepath.Dir(b.path), p)
+}
+
+// id returns a value identifying the given file, used to detect include cycles.
+func (b *Backend) id(p string) string {
+	if b.fsys != nil || b.remote != nil || p == "" {
+		return p
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	return abs
+}
+
+func includePaths(v any) ([]string, error) {
+	switch t := v.(type) {
+	case string:
+		return []string{t}, nil
+	case []string:
+		return t, nil
+	case []any:
+		paths := make([]string, len(t))
+		for i, p := range t {
+			s, ok := p.(string)
+			if !ok {
+				return nil, errors.Errorf("expected a path, got %v", p)
+			}
+			paths[i] = s
+		}
+		return paths, nil
+	}
+
+	return nil, errors.Errorf("expected a path or a list of paths, got %v", v)
+}
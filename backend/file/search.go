@@ -0,0 +1,151 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heetch/confita/backend"
+	"github.com/pkg/errors"
+)
+
+// DefaultSearchPaths returns the directories where the configuration files of the given
+// application are usually stored, by decreasing priority: the working directory,
+// $XDG_CONFIG_HOME/app, $HOME/.config/app, $HOME/.app and /etc/app.
+func DefaultSearchPaths(app string) []string {
+	return []string{
+		".",
+		filepath.Join("$XDG_CONFIG_HOME", app),
+		filepath.Join("$HOME", ".config", app),
+		filepath.Join("$HOME", "."+app),
+		filepath.Join("/etc", app),
+	}
+}
+
+// NewSearchBackend creates a configuration loader that loads from the first file with the given name
+// found in paths. Paths can contain environment variables, e.g. "$HOME/.app", and paths referring to
+// an unset variable are skipped. If no file is found, backend.ErrNotFound is returned.
+// The file that was used is reported by the Files method.
+func NewSearchBackend(name string, paths ...string) *Backend {
+	return newSearchBackend(name, paths, false, nil)
+}
+
+// NewSearchBackendWithOptions is like NewSearchBackend but applies the given options to the file that is found.
+func NewSearchBackendWithOptions(name string, paths []string, opts ...Option) *Backend {
+	return newSearchBackend(name, paths, false, opts)
+}
+
+// NewSearchAllBackend is like NewSearchBackend but loads all the files found in paths and merges them
+// as NewDirBackend does, values defined in the first paths taking precedence.
+func NewSearchAllBackend(name string, paths ...string) *Backend {
+	return newSearchBackend(name, paths, true, nil)
+}
+
+// NewSearchAllBackendWithOptions is like NewSearchAllBackend but applies the given options to the files that are found.
+func NewSearchAllBackendWithOptions(name string, paths []string, opts ...Option) *Backend {
+	return newSearchBackend(name, paths, true, opts)
+}
+
+func newSearchBackend(name string, paths []string, all bool, opts []Option) *Backend {
+	b := Backend{
+		path: name,
+		name: strings.TrimPrefix(filepath.Ext(name), "."),
+	}
+	b.read = func(ctx context.Context) (map[string]any, error) {
+		return b.loadSearch(ctx, name, paths, all)
+	}
+	b.candidates = func() ([]string, []string) {
+		var files []string
+		for _, p := range paths {
+			if dir, ok := expandPath(p); ok {
+				files = append(files, filepath.Join(dir, name))
+			}
+		}
+		return files, nil
+	}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+func (b *Backend) loadSearch(ctx context.Context, name string, paths []string, all bool) (map[string]any, error) {
+	var found []string
+	for _, p := range paths {
+		dir, ok := expandPath(p)
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to open file at path \"%s\"", path)
+		}
+		if fi.IsDir() {
+			continue
+		}
+
+		found = append(found, path)
+		if !all {
+			break
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, backend.ErrNotFound
+	}
+
+	m := merger{
+		doc:     make(map[string]any),
+		origins: make(map[string]string),
+	}
+
+	// files with a lower priority are merged first so that they get overridden.
+	for i := len(found) - 1; i >= 0; i-- {
+		doc, err := b.fileBackend(found[i]).load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.merge(m.doc, doc, "", found[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.files = found
+	return m.doc, nil
+}
+
+// expandPath replaces the environment variables and the leading "~" of path.
+// It reports false if path refers to a variable that is not set.
+func expandPath(path string) (string, bool) {
+	ok := true
+	path = os.Expand(path, func(name string) string {
+		v := os.Getenv(name)
+		if v == "" {
+			ok = false
+		}
+		return v
+	})
+	if !ok {
+		return "", false
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	return path, true
+}
@@ -0,0 +1,323 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultDebounce     = 100 * time.Millisecond
+	defaultPollInterval = time.Second
+	// defaultHTTPPollInterval is used to poll the files fetched over HTTP.
+	defaultHTTPPollInterval = 30 * time.Second
+)
+
+// WithDebounce sets how long Watch waits for the files to stop changing before reporting a change,
+// so that the bursts of events produced by editors and atomic renames result in a single notification.
+// It defaults to 100ms.
+func WithDebounce(d time.Duration) Option {
+	return func(b *Backend) {
+		b.debounce = d
+	}
+}
+
+// WithPolling makes Watch poll the files at the given interval instead of relying on
+// the notifications of the operating system, e.g. for network file systems.
+func WithPolling(interval time.Duration) Option {
+	return func(b *Backend) {
+		b.pollInterval = interval
+		b.forcePoll = true
+	}
+}
+
+// A notifier reports the events occurring in a set of directories.
+type notifier interface {
+	// Add starts watching the given directory. Adding a directory twice has no effect.
+	Add(dir string) error
+	// Events returns a channel receiving a value when an event occurs.
+	// It is closed if the notifier stops working.
+	Events() <-chan struct{}
+	Close() error
+}
+
+// Watch watches the files the configuration is loaded from and sends a value on the returned channel
+// every time their content changes. The configuration is then read again by the next call to Get.
+//
+// On Linux, changes are detected using inotify, otherwise or if inotify is not available,
+// files are polled every second, or at the interval set using WithPolling.
+// In both cases, a change is reported only if the content of a file changed, a file appeared
+// or a file disappeared. The directories of the files are watched rather than the files themselves
+// so that files replaced by atomic renames, or updated through symbolic links like the files
+// mounted from Kubernetes ConfigMaps and Secrets, are handled correctly.
+//
+// Files fetched over HTTP are polled, see NewHTTPBackend. Files loaded from an fs.FS,
+// an io.Reader or a byte slice are considered to never change.
+// The channel is closed once ctx is done.
+func (b *Backend) Watch(ctx context.Context) (<-chan struct{}, error) {
+	ch := make(chan struct{}, 1)
+
+	if b.remote != nil {
+		go b.watchRemote(ctx, ch)
+		return ch, nil
+	}
+
+	if b.path == "" || b.fsys != nil {
+		go func() {
+			<-ctx.Done()
+			close(ch)
+		}()
+		return ch, nil
+	}
+
+	var n notifier
+	if !b.forcePoll {
+		// fall back to polling if inotify is not available
+		n, _ = newNotifier()
+	}
+
+	// start watching before taking the first snapshot so that no change is missed
+	poll := b.watchDirs(n)
+	snap := b.snapshot(nil)
+	go b.watch(ctx, n, poll, snap, ch)
+	return ch, nil
+}
+
+// watchDirs makes n watch the directories of the files, including the ones that appeared
+// since the last call. It reports whether polling is needed, because n is nil or
+// some directories can't be watched.
+func (b *Backend) watchDirs(n notifier) bool {
+	if n == nil {
+		return true
+	}
+
+	var poll bool
+	_, dirs := b.watched()
+	for _, dir := range dirs {
+		if n.Add(dir) != nil {
+			poll = true
+		}
+	}
+	return poll
+}
+
+func (b *Backend) watch(ctx context.Context, n notifier, poll bool, snap snapshot, ch chan struct{}) {
+	defer close(ch)
+
+	var events <-chan struct{}
+	if n != nil {
+		defer n.Close()
+		events = n.Events()
+	}
+
+	debounce := b.debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+	interval := b.pollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	// polled is the last snapshot taken while polling, a change is reported once
+	// it stops changing.
+	polled := snap
+
+	var ticker *time.Ticker
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		var tick <-chan time.Time
+		if poll {
+			if ticker == nil {
+				ticker = time.NewTicker(interval)
+			}
+			tick = ticker.C
+		} else if ticker != nil {
+			ticker.Stop()
+			ticker = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				n.Close()
+				n, events, poll = nil, nil, true
+				continue
+			}
+			poll = b.watchDirs(n)
+			timer.Reset(debounce)
+		case <-tick:
+			poll = b.watchDirs(n)
+			next := b.snapshot(polled)
+			if next.changed(polled) {
+				polled = next
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			next := b.snapshot(snap)
+			if !next.changed(snap) {
+				continue
+			}
+			snap, polled = next, next
+
+			b.reset()
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// watchRemote polls the file fetched over HTTP. Errors are ignored, as they
+// are reported when the configuration is loaded again.
+func (b *Backend) watchRemote(ctx context.Context, ch chan struct{}) {
+	defer close(ch)
+
+	interval := b.pollInterval
+	if interval <= 0 {
+		interval = defaultHTTPPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := b.fetch(ctx)
+		if err != nil || !changed {
+			continue
+		}
+
+		b.reset()
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watched returns the files the configuration is loaded from, or could be loaded from,
+// and the directories to watch.
+func (b *Backend) watched() (files, dirs []string) {
+	if b.candidates != nil {
+		files, dirs = b.candidates()
+	} else {
+		files = []string{b.path}
+	}
+	files = append(files, b.Files()...)
+
+	seen := make(map[string]bool)
+	for _, f := range files {
+		dirs = append(dirs, filepath.Dir(f))
+	}
+	out := dirs[:0]
+	for _, d := range dirs {
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+
+	return files, out
+}
+
+// reset drops the cached configuration so that it's read again.
+func (b *Backend) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.doc = nil
+}
+
+// modTimeGranularity is the largest precision of the modification times
+// among the common file systems.
+const modTimeGranularity = 2 * time.Second
+
+type fingerprint struct {
+	modTime  time.Time
+	size     int64
+	hash     []byte
+	hashedAt time.Time
+}
+
+// upToDate reports whether fp still describes a file whose modification time and size
+// are the given ones. Files modified shortly before being hashed could have been modified
+// again without their modification time changing.
+func (fp fingerprint) upToDate(modTime time.Time, size int64) bool {
+	return fp.modTime.Equal(modTime) && fp.size == size && fp.hashedAt.Sub(fp.modTime) > modTimeGranularity
+}
+
+// snapshot holds the fingerprints of the existing files.
+type snapshot map[string]fingerprint
+
+// snapshot fingerprints the watched files. The content of the files that are still
+// up to date in prev is not hashed again.
+func (b *Backend) snapshot(prev snapshot) snapshot {
+	files, _ := b.watched()
+
+	snap := make(snapshot)
+	for _, f := range files {
+		if _, ok := snap[f]; ok {
+			continue
+		}
+
+		// follow symbolic links
+		fi, err := os.Stat(f)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+
+		fp := fingerprint{modTime: fi.ModTime(), size: fi.Size()}
+		if p, ok := prev[f]; ok && p.upToDate(fp.modTime, fp.size) {
+			fp.hash, fp.hashedAt = p.hash, p.hashedAt
+		} else {
+			fp.hashedAt = time.Now()
+			data, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(data)
+			fp.hash = sum[:]
+		}
+
+		snap[f] = fp
+	}
+
+	return snap
+}
+
+// changed reports whether files appeared, disappeared or got a different content since prev.
+func (s snapshot) changed(prev snapshot) bool {
+	if len(s) != len(prev) {
+		return true
+	}
+
+	for f, fp := range s {
+		p, ok := prev[f]
+		if !ok || !bytes.Equal(p.hash, fp.hash) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotify is a notifier relying on the inotify API of Linux.
+type inotify struct {
+	fd     int
+	f      *os.File
+	events chan struct{}
+
+	mu   sync.Mutex
+	dirs map[string]int32
+	wds  map[int32]string
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	n := inotify{
+		fd: fd,
+		// the file descriptor being non blocking, reads are handled by the runtime poller
+		// and are interrupted by Close.
+		f:      os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+		dirs:   make(map[string]int32),
+		wds:    make(map[int32]string),
+	}
+
+	go n.read()
+	return &n, nil
+}
+
+func (n *inotify) read() {
+	defer close(n.events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		count, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		// the files are compared once the events stop, only the events reporting
+		// that a directory is not watched anymore are of interest.
+		for i := 0; i+syscall.SizeofInotifyEvent <= count; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[i]))
+			if ev.Mask&(syscall.IN_IGNORED|syscall.IN_MOVE_SELF) != 0 {
+				n.remove(ev.Wd)
+			}
+			i += syscall.SizeofInotifyEvent + int(ev.Len)
+		}
+
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotify) Add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.dirs[dir]; ok {
+		return nil
+	}
+
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	n.dirs[dir] = int32(wd)
+	n.wds[int32(wd)] = dir
+	return nil
+}
+
+// remove forgets a directory that was removed or moved, so that it's watched again
+// by the next call to Add if it's recreated.
+func (n *inotify) remove(wd int32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	dir, ok := n.wds[wd]
+	if !ok {
+		return
+	}
+
+	syscall.InotifyRmWatch(n.fd, uint32(wd))
+	delete(n.wds, wd)
+	delete(n.dirs, dir)
+}
+
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+func (n *inotify) Close() error {
+	return n.f.Close()
+}
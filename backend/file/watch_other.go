@@ -0,0 +1,9 @@
+//go:build !linux
+
+package file
+
+import "errors"
+
+func newNotifier() (notifier, error) {
+	return nil, errors.New("file notifications not supported on this platform")
+}
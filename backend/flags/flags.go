@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/heetch/confita"
@@ -15,104 +16,247 @@ import (
 // Backend that loads configuration from the command line flags.
 type Backend struct {
 	flags *flag.FlagSet
+	args  []string
+
+	errorHandling    flag.ErrorHandling
+	setErrorHandling bool
+
+	// in POSIX style, short flags aren't defined on the flag set but mapped to the long ones.
+	posix  bool
+	shorts map[string]string
+}
+
+// Option is used to configure the flags backend.
+type Option func(*Backend)
+
+// WithFlagSet makes the backend define its flags on fs instead of flag.CommandLine,
+// e.g. to share it with other flags or to use it in tests.
+func WithFlagSet(fs *flag.FlagSet) Option {
+	return func(b *Backend) {
+		b.flags = fs
+	}
 }
 
-// NewBackend creates a flags backend.
-func NewBackend() *Backend {
-	return &Backend{
-		flags: flag.CommandLine,
+// WithArgs sets the arguments to parse instead of os.Args[1:].
+func WithArgs(args []string) Option {
+	return func(b *Backend) {
+		b.args = args
 	}
 }
 
+// WithErrorHandling sets how parsing errors are handled. Unless WithFlagSet is used,
+// the backend then uses its own flag set instead of flag.CommandLine, which exits on errors.
+// With flag.ContinueOnError, the errors, including flag.ErrHelp, are returned by the loader.
+func WithErrorHandling(h flag.ErrorHandling) Option {
+	return func(b *Backend) {
+		b.errorHandling = h
+		b.setErrorHandling = true
+	}
+}
+
+// NewBackend creates a flags backend. By default, it defines its flags on flag.CommandLine
+// and parses os.Args[1:].
+func NewBackend(opts ...Option) *Backend {
+	var b Backend
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	switch {
+	case b.flags != nil && b.setErrorHandling:
+		b.flags.Init(b.flags.Name(), b.errorHandling)
+	case b.setErrorHandling:
+		b.flags = flag.NewFlagSet(os.Args[0], b.errorHandling)
+	case b.flags == nil:
+		b.flags = flag.CommandLine
+	}
+
+	if b.posix {
+		b.shorts = make(map[string]string)
+		b.flags.Usage = b.usage
+	}
+
+	return &b
+}
+
 // LoadStruct takes a struct config, define flags based on it and parse the command line args.
+// It can be called several times: flags that are already defined are reused.
 func (b *Backend) LoadStruct(ctx context.Context, cfg *confita.StructConfig) error {
-	for _, field := range cfg.Fields {
-		f := field
+	var fields []*confita.FieldConfig
 
+	for _, f := range cfg.Fields {
 		if f.Backend != "" && f.Backend != b.Name() {
 			continue
 		}
 
-		// Display all the flags and their default values but override the field only if the user has explicitely
-		// set the flag.
+		// slices and maps of structs can't be expressed as a single flag.
+		if f.Composite {
+			continue
+		}
+
+		err := b.define(f)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, f)
+	}
+
+	// Note: in the usual case, when b.flags is flag.CommandLine, this will exit
+	// rather than returning an error.
+	var err error
+	if b.posix {
+		err = b.parsePOSIX(b.arguments())
+	} else {
+		err = b.flags.Parse(b.arguments())
+	}
+	if err != nil {
+		return err
+	}
+
+	// Display all the flags and their default values but override the field only if the user has explicitely
+	// set the flag.
+	for _, f := range fields {
+		if b.isFlagSet(f) {
+			assign(f, b.flags.Lookup(f.Key).Value)
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) arguments() []string {
+	if b.args != nil {
+		return b.args
+	}
+
+	return os.Args[1:]
+}
+
+// define defines the flags of the given field, using the same variable for the short flag,
+// unless they are already defined. It returns an error if the flag is already defined with
+// a type that doesn't match the one of the field.
+func (b *Backend) define(f *confita.FieldConfig) error {
+	if fl := b.flags.Lookup(f.Key); fl != nil {
+		// values set during parsing must be stored in the new field.
+		if fv, ok := fl.Value.(*flagValue); ok {
+			if fv.Value.Type() != f.Value.Type() {
+				return mismatch(f)
+			}
+			fv.FieldConfig = f
+			return nil
+		}
+
+		if !compatible(f, fl.Value) {
+			return mismatch(f)
+		}
+		return nil
+	}
+
+	names := []string{f.Key}
+	if f.Short != "" && b.flags.Lookup(f.Short) == nil {
+		if b.posix {
+			if _, ok := b.shorts[f.Short]; !ok {
+				b.shorts[f.Short] = f.Key
+			}
+		} else {
+			names = append(names, f.Short)
+		}
+	}
+
+	var value flag.Value
+	for i, name := range names {
+		usage := f.Description
+		if i > 0 {
+			usage = shortDesc(usage)
+		}
+
+		if value != nil {
+			b.flags.Var(value, name, usage)
+			continue
+		}
+
 		k := f.Value.Kind()
 		switch {
-		case f.Value.Type().String() == "time.Duration":
-			var val time.Duration
-			b.flags.DurationVar(&val, f.Key, time.Duration(f.Default.Int()), f.Description)
-			if f.Short != "" {
-				b.flags.DurationVar(&val, f.Short, time.Duration(f.Default.Int()), shortDesc(f.Description))
-			}
-			// this function must be executed after the flag.Parse call.
-			defer func() {
-				// if the user has set the flag, save the value in the field.
-				if b.isFlagSet(f) {
-					f.Value.SetInt(int64(val))
-				}
-			}()
+		case f.Value.Type() == durationType:
+			// durations are parsed by the field so that the extended syntax can be used.
+			b.flags.Var(&flagValue{f}, name, usage)
 		case k == reflect.Bool:
-			var val bool
-			b.flags.BoolVar(&val, f.Key, f.Default.Bool(), f.Description)
-			if f.Short != "" {
-				b.flags.BoolVar(&val, f.Short, f.Default.Bool(), shortDesc(f.Description))
-			}
-			defer func() {
-				if b.isFlagSet(f) {
-					f.Value.SetBool(val)
-				}
-			}()
+			b.flags.Bool(name, f.Default.Bool(), usage)
 		case k >= reflect.Int && k <= reflect.Int64:
-			var val int
-			b.flags.IntVar(&val, f.Key, int(f.Default.Int()), f.Description)
-			if f.Short != "" {
-				b.flags.IntVar(&val, f.Short, int(f.Default.Int()), shortDesc(f.Description))
-			}
-			defer func() {
-				if b.isFlagSet(f) {
-					f.Value.SetInt(int64(val))
-				}
-			}()
+			b.flags.Int(name, int(f.Default.Int()), usage)
 		case k >= reflect.Uint && k <= reflect.Uint64:
-			var val uint64
-			b.flags.Uint64Var(&val, f.Key, f.Default.Uint(), f.Description)
-			if f.Short != "" {
-				b.flags.Uint64Var(&val, f.Short, f.Default.Uint(), shortDesc(f.Description))
-			}
-			defer func() {
-				if b.isFlagSet(f) {
-					f.Value.SetUint(val)
-				}
-			}()
+			b.flags.Uint64(name, f.Default.Uint(), usage)
 		case k >= reflect.Float32 && k <= reflect.Float64:
-			var val float64
-			b.flags.Float64Var(&val, f.Key, f.Default.Float(), f.Description)
-			if f.Short != "" {
-				b.flags.Float64Var(&val, f.Short, f.Default.Float(), shortDesc(f.Description))
-			}
-			defer func() {
-				if b.isFlagSet(f) {
-					f.Value.SetFloat(val)
-				}
-			}()
+			b.flags.Float64(name, f.Default.Float(), usage)
 		case k == reflect.String:
-			var val string
-			b.flags.StringVar(&val, f.Key, f.Default.String(), f.Description)
-			if f.Short != "" {
-				b.flags.StringVar(&val, f.Short, f.Default.String(), shortDesc(f.Description))
-			}
-			defer func() {
-				if b.isFlagSet(f) {
-					f.Value.SetString(val)
-				}
-			}()
+			b.flags.String(name, f.Default.String(), usage)
 		default:
-			b.flags.Var(&flagValue{f}, f.Key, f.Description)
+			b.flags.Var(&flagValue{f}, name, usage)
 		}
+		value = b.flags.Lookup(name).Value
 	}
 
-	// Note: in the usual case, when b.flags is flag.CommandLine, this will exit
-	// rather than returning an error.
-	return b.flags.Parse(os.Args[1:])
+	return nil
+}
+
+func mismatch(f *confita.FieldConfig) error {
+	return fmt.Errorf("flag '%s' is already defined with a type that doesn't match the one of field '%s'", f.Key, f.Name)
+}
+
+// compatible reports whether the value of a flag defined by the application can be stored in the field.
+func compatible(f *confita.FieldConfig, value flag.Value) bool {
+	g, ok := value.(flag.Getter)
+	if !ok {
+		return false
+	}
+
+	k := f.Value.Kind()
+	isDuration := f.Value.Type() == durationType
+	switch g.Get().(type) {
+	case time.Duration:
+		return isDuration
+	case bool:
+		return k == reflect.Bool
+	case int, int64:
+		return k >= reflect.Int && k <= reflect.Int64 && !isDuration
+	case uint, uint64:
+		return k >= reflect.Uint && k <= reflect.Uint64
+	case float64:
+		return k >= reflect.Float32 && k <= reflect.Float64
+	case string:
+		return k == reflect.String
+	}
+
+	return false
+}
+
+// assign stores the value of the given flag in the field. Values of the other types
+// are stored by flagValue during parsing.
+func assign(f *confita.FieldConfig, value flag.Value) {
+	g, ok := value.(flag.Getter)
+	if !ok {
+		return
+	}
+
+	switch v := g.Get().(type) {
+	case time.Duration:
+		f.Value.SetInt(int64(v))
+	case bool:
+		f.Value.SetBool(v)
+	case int:
+		f.Value.SetInt(int64(v))
+	case int64:
+		f.Value.SetInt(v)
+	case uint:
+		f.Value.SetUint(uint64(v))
+	case uint64:
+		f.Value.SetUint(v)
+	case float64:
+		f.Value.SetFloat(v)
+	case string:
+		f.Value.SetString(v)
+	}
 }
 
 func (b *Backend) isFlagSet(config *confita.FieldConfig) bool {
@@ -125,20 +269,44 @@ func (b *Backend) isFlagSet(config *confita.FieldConfig) bool {
 	return ok
 }
 
+var durationType = reflect.TypeOf(time.Duration(0))
+
 type flagValue struct {
 	*confita.FieldConfig
 }
 
+// String returns the default value of the field, or an empty string if it's the zero value,
+// so that it's displayed in the usage message.
 func (f *flagValue) String() string {
-	if f.FieldConfig == nil {
+	if f.FieldConfig == nil || !f.Default.IsValid() || f.Default.IsZero() {
 		return ""
 	}
 
-	return f.Key
+	return formatValue(f.FieldConfig, f.Default)
 }
 
-func (f *flagValue) Get() any {
-	return f.Default.Interface()
+// formatValue formats v the way it's expected on the command line, e.g. "a,b" for a slice of strings.
+func formatValue(f *confita.FieldConfig, v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		return formatValue(f, v.Elem())
+	case reflect.Slice, reflect.Array:
+		sep := f.ListSeparator
+		if sep == "" {
+			sep = ","
+		}
+
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = formatValue(f, v.Index(i))
+		}
+		return strings.Join(elems, sep)
+	}
+
+	return fmt.Sprint(v.Interface())
 }
 
 // Get is not implemented.
@@ -1,8 +1,10 @@
 package flags
 
 import (
+	"bytes"
 	"context"
 	"flag"
+	"io"
 	"os"
 	"testing"
 	"time"
@@ -17,7 +19,7 @@ func runHelper(t *testing.T, cfg any, args ...string) {
 
 	flags := flag.NewFlagSet("test", flag.ContinueOnError)
 	os.Args = append([]string{"a.out"}, args...)
-	err := confita.NewLoader(&Backend{flags}).Load(context.Background(), cfg)
+	err := confita.NewLoader(NewBackend(WithFlagSet(flags))).Load(context.Background(), cfg)
 	require.NoError(t, err)
 }
 
@@ -144,7 +146,7 @@ func TestWithAnotherBackend(t *testing.T) {
 
 	flags := flag.NewFlagSet("test", flag.ContinueOnError)
 	os.Args = append([]string{"a.out"}, "-int=42", "-string=string", "-float=99.5")
-	err := confita.NewLoader(st, &Backend{flags}).Load(context.Background(), &cfg)
+	err := confita.NewLoader(st, NewBackend(WithFlagSet(flags))).Load(context.Background(), &cfg)
 	require.NoError(t, err)
 
 	require.Equal(t, "string", cfg.String)
@@ -155,6 +157,193 @@ func TestWithAnotherBackend(t *testing.T) {
 	require.Equal(t, time.Duration(1), cfg.Duration)
 }
 
+func TestOptions(t *testing.T) {
+	type config struct {
+		Host    string        `config:"host,short=h"`
+		Port    int           `config:"port"`
+		Timeout time.Duration `config:"timeout"`
+		Tags    []string      `config:"tags"`
+	}
+
+	t.Run("Args", func(t *testing.T) {
+		os.Args = []string{"a.out", "-port=1"}
+
+		var cfg config
+		b := NewBackend(WithFlagSet(flag.NewFlagSet("test", flag.ContinueOnError)), WithArgs([]string{"-h", "example.com", "-port=2"}))
+		err := confita.NewLoader(b).Load(context.Background(), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, config{Host: "example.com", Port: 2}, cfg)
+	})
+
+	t.Run("ErrorHandling", func(t *testing.T) {
+		var cfg config
+		b := NewBackend(WithErrorHandling(flag.ContinueOnError), WithArgs([]string{"-unknown"}))
+		b.flags.SetOutput(io.Discard)
+		err := confita.NewLoader(b).Load(context.Background(), &cfg)
+		require.EqualError(t, err, "flag provided but not defined: -unknown")
+		require.True(t, b.flags != flag.CommandLine)
+
+		fs := flag.NewFlagSet("test", flag.ExitOnError)
+		fs.SetOutput(io.Discard)
+		b = NewBackend(WithFlagSet(fs), WithErrorHandling(flag.ContinueOnError), WithArgs([]string{"-help"}))
+		err = confita.NewLoader(b).Load(context.Background(), &cfg)
+		require.Equal(t, flag.ErrHelp, err)
+	})
+
+	t.Run("ExtendedDurations", func(t *testing.T) {
+		for _, opts := range [][]Option{nil, {WithPOSIXStyle()}} {
+			var cfg config
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			l := confita.NewLoader(NewBackend(append(opts, WithFlagSet(fs), WithArgs([]string{"--timeout=7d"}))...))
+			l.ExtendedDurations = true
+			err := l.Load(context.Background(), &cfg)
+			require.NoError(t, err)
+			require.Equal(t, 7*24*time.Hour, cfg.Timeout)
+		}
+
+		var cfg config
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		err := confita.NewLoader(NewBackend(WithFlagSet(fs), WithArgs([]string{"--timeout=7d"}))).Load(context.Background(), &cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("SharedFlagSet", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int64("port", 0, "defined by the application")
+		fs.Duration("timeout", 0, "defined by the application")
+
+		var cfg config
+		b := NewBackend(WithFlagSet(fs), WithArgs([]string{"-port=2", "-timeout=1s"}))
+		err := confita.NewLoader(b).Load(context.Background(), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, config{Port: 2, Timeout: time.Second}, cfg)
+
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("port", "", "defined by the application")
+
+		b = NewBackend(WithFlagSet(fs), WithArgs([]string{"-port=2"}))
+		err = confita.NewLoader(b).Load(context.Background(), &cfg)
+		require.EqualError(t, err, "flag 'port' is already defined with a type that doesn't match the one of field 'Port'")
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		require.True(t, NewBackend().flags == flag.CommandLine)
+	})
+
+	t.Run("Repeated", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("other", "", "defined by the application")
+		b := NewBackend(WithFlagSet(fs), WithArgs([]string{"-h=example.com", "-timeout=1s", "-tags=a,b", "-other=x"}))
+		l := confita.NewLoader(b)
+
+		for i := 0; i < 2; i++ {
+			var cfg config
+			err := l.Load(context.Background(), &cfg)
+			require.NoError(t, err)
+			require.Equal(t, config{Host: "example.com", Timeout: time.Second, Tags: []string{"a", "b"}}, cfg)
+		}
+	})
+}
+
+func TestPOSIXStyle(t *testing.T) {
+	type config struct {
+		Host    string        `config:"host,short=h,description=the host to listen on"`
+		Port    int           `config:"port,short=p"`
+		Timeout time.Duration `config:"timeout"`
+		Verbose bool          `config:"verbose,short=v"`
+		Extra   bool          `config:"extra,short=x"`
+		Cache   bool          `config:"cache"`
+		Tags    []string      `config:"tags,short=t"`
+		Ratio   float64       `config:"r"`
+	}
+
+	load := func(t *testing.T, cfg *config, args ...string) (*flag.FlagSet, error) {
+		t.Helper()
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		err := confita.NewLoader(NewBackend(WithFlagSet(fs), WithPOSIXStyle(), WithArgs(args))).Load(context.Background(), cfg)
+		return fs, err
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want config
+	}{
+		{"Long", []string{"--host", "example.com", "--port=8080", "--timeout", "1s", "--verbose", "--r=0.5"}, config{Host: "example.com", Port: 8080, Timeout: time.Second, Verbose: true, Cache: true, Ratio: 0.5}},
+		{"Short", []string{"-h", "example.com", "-p8080", "-t=a,b", "-r", "0.5"}, config{Host: "example.com", Port: 8080, Cache: true, Tags: []string{"a", "b"}, Ratio: 0.5}},
+		{"Combined", []string{"-vx"}, config{Verbose: true, Extra: true, Cache: true}},
+		{"CombinedWithValue", []string{"-vxp", "8080"}, config{Port: 8080, Verbose: true, Extra: true, Cache: true}},
+		{"Negation", []string{"--no-cache", "--verbose=false", "-x=false"}, config{}},
+		{"Positional", []string{"-v", "file", "--port=1"}, config{Verbose: true, Cache: true}},
+		{"Terminator", []string{"-v", "--", "--port=1"}, config{Verbose: true, Cache: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config{Cache: true}
+			_, err := load(t, &cfg, test.args...)
+			require.NoError(t, err)
+			require.Equal(t, test.want, cfg)
+		})
+	}
+
+	t.Run("RemainingArgs", func(t *testing.T) {
+		var cfg config
+		fs, err := load(t, &cfg, "-v", "--", "--port=1", "file")
+		require.NoError(t, err)
+		require.Equal(t, []string{"--port=1", "file"}, fs.Args())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		errs := map[string][]string{
+			"flag provided but not defined: --unknown":   {"--unknown"},
+			"flag provided but not defined: -y":          {"-vy"},
+			"flag needs an argument: --host":             {"--host"},
+			"flag needs an argument: -p":                 {"-vp"},
+			`invalid value "a" for flag -p: parse error`: {"-p", "a"},
+			"flag provided but not defined: --no-port":   {"--no-port"},
+		}
+
+		for msg, args := range errs {
+			var cfg config
+			_, err := load(t, &cfg, args...)
+			require.EqualError(t, err, msg)
+		}
+
+		var cfg config
+		_, err := load(t, &cfg, "--help")
+		require.Equal(t, flag.ErrHelp, err)
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(&buf)
+		cfg := config{Host: "127.0.0.1", Timeout: 10 * time.Second, Tags: []string{"a", "b"}}
+		err := confita.NewLoader(NewBackend(WithFlagSet(fs), WithPOSIXStyle(), WithArgs([]string{"--help"}))).Load(context.Background(), &cfg)
+		require.Equal(t, flag.ErrHelp, err)
+
+		require.Equal(t, `Usage of test:
+  --cache
+  -x, --extra
+  -h, --host string
+    	the host to listen on (default "127.0.0.1")
+  -p, --port int
+  -r float
+  -t, --tags value
+    	(default a,b)
+  --timeout duration
+    	(default 10s)
+  -v, --verbose
+`, buf.String())
+	})
+}
+
 type store map[string]string
 
 func (s store) Get(ctx context.Context, key string) ([]byte, error) {
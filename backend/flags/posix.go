@@ -0,0 +1,248 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// WithPOSIXStyle makes the backend parse the arguments following the GNU/POSIX conventions
+// instead of the ones of the flag package:
+//   - long flags start with two dashes: --host example.com or --host=example.com
+//   - short flags start with a single dash: -h example.com, -hexample.com or -h=example.com
+//   - boolean short flags can be combined, e.g. -vx for -v -x, and the last one can take a value, e.g. -vp8080
+//   - boolean flags can be negated using the "no-" prefix, e.g. --no-verbose
+//
+// Short names are expected to be a single character. Longer ones can be used with two dashes.
+// Flags are listed once in the usage message, along with their short name, e.g. "-h, --host string".
+// The usage function of the flag set is replaced, so a custom one must be set after calling NewBackend.
+func WithPOSIXStyle() Option {
+	return func(b *Backend) {
+		b.posix = true
+	}
+}
+
+// parsePOSIX sets the flags found at the beginning of args, then lets the flag set
+// handle the remaining arguments.
+func (b *Backend) parsePOSIX(args []string) error {
+	for len(args) > 0 {
+		arg := args[0]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			break
+		}
+		args = args[1:]
+
+		var err error
+		if strings.HasPrefix(arg, "--") {
+			args, err = b.parseLong(arg[2:], args)
+		} else {
+			args, err = b.parseShort(arg[1:], args)
+		}
+		if err != nil {
+			return b.fail(err)
+		}
+	}
+
+	return b.flags.Parse(args)
+}
+
+// parseLong parses the flag s, found after two dashes, and returns the remaining arguments.
+func (b *Backend) parseLong(s string, args []string) ([]string, error) {
+	name, value, hasValue := strings.Cut(s, "=")
+
+	fl := b.lookup(name)
+	if fl == nil {
+		if neg, ok := strings.CutPrefix(name, "no-"); ok && !hasValue {
+			if fl = b.lookup(neg); fl != nil && isBoolFlag(fl) {
+				return args, b.set(fl, "--"+name, "false")
+			}
+		}
+
+		return args, undefined("--"+name, name)
+	}
+
+	return b.setValue(fl, "--"+name, value, hasValue, args)
+}
+
+// parseShort parses the short flags s, found after a single dash, and returns the remaining arguments.
+func (b *Backend) parseShort(s string, args []string) ([]string, error) {
+	for i := 0; i < len(s); i++ {
+		name := s[i : i+1]
+
+		fl := b.lookup(name)
+		if fl == nil {
+			return args, undefined("-"+name, name)
+		}
+
+		rest := s[i+1:]
+		if isBoolFlag(fl) && !strings.HasPrefix(rest, "=") {
+			if err := b.set(fl, "-"+name, "true"); err != nil {
+				return args, err
+			}
+			continue
+		}
+
+		// the rest of the argument is the value, e.g. -p8080
+		return b.setValue(fl, "-"+name, strings.TrimPrefix(rest, "="), rest != "", args)
+	}
+
+	return args, nil
+}
+
+// lookup returns the flag with the given long or short name.
+func (b *Backend) lookup(name string) *flag.Flag {
+	if fl := b.flags.Lookup(name); fl != nil {
+		return fl
+	}
+
+	if long, ok := b.shorts[name]; ok {
+		return b.flags.Lookup(long)
+	}
+
+	return nil
+}
+
+// setValue sets the flag to the given value or, if there is none, to true for boolean flags
+// and to the next argument for the others.
+func (b *Backend) setValue(fl *flag.Flag, arg, value string, hasValue bool, args []string) ([]string, error) {
+	if !hasValue {
+		switch {
+		case isBoolFlag(fl):
+			value = "true"
+		case len(args) == 0:
+			return args, fmt.Errorf("flag needs an argument: %s", arg)
+		default:
+			value, args = args[0], args[1:]
+		}
+	}
+
+	return args, b.set(fl, arg, value)
+}
+
+func (b *Backend) set(fl *flag.Flag, arg, value string) error {
+	err := b.flags.Set(fl.Name, value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for flag %s: %v", value, arg, err)
+	}
+
+	return nil
+}
+
+// undefined returns the error reported for an unknown flag. Like the flag package,
+// it reports a request for help if the flag is named help or h.
+func undefined(arg, name string) error {
+	if name == "help" || name == "h" {
+		return flag.ErrHelp
+	}
+
+	return fmt.Errorf("flag provided but not defined: %s", arg)
+}
+
+// fail reports the error as done by the flag set, according to its error handling.
+func (b *Backend) fail(err error) error {
+	if !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(b.flags.Output(), err)
+	}
+
+	if b.flags.Usage != nil {
+		b.flags.Usage()
+	} else {
+		b.usage()
+	}
+
+	switch b.flags.ErrorHandling() {
+	case flag.ExitOnError:
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case flag.PanicOnError:
+		panic(err)
+	}
+
+	return err
+}
+
+// usage prints the flags in the GNU/POSIX style, e.g.:
+//
+//	Usage of ./bin:
+//	  -h, --host string
+//	    	the host to listen on (default "127.0.0.1")
+func (b *Backend) usage() {
+	out := b.flags.Output()
+	if b.flags.Name() == "" {
+		fmt.Fprintf(out, "Usage:\n")
+	} else {
+		fmt.Fprintf(out, "Usage of %s:\n", b.flags.Name())
+	}
+
+	shorts := make(map[string]string, len(b.shorts))
+	for short, long := range b.shorts {
+		shorts[long] = short
+	}
+
+	b.flags.VisitAll(func(fl *flag.Flag) {
+		var sb strings.Builder
+
+		sb.WriteString("  ")
+		if short, ok := shorts[fl.Name]; ok {
+			sb.WriteString(dashes(short) + short + ", ")
+		}
+		sb.WriteString(dashes(fl.Name) + fl.Name)
+
+		name, usage := flag.UnquoteUsage(fl)
+		if fv, ok := fl.Value.(*flagValue); ok && fv.Value.Type() == durationType {
+			name = "duration"
+		}
+		if name != "" {
+			sb.WriteString(" " + name)
+		}
+
+		switch {
+		case isZeroValue(fl):
+		case name == "string":
+			usage = strings.TrimSpace(fmt.Sprintf("%s (default %q)", usage, fl.DefValue))
+		default:
+			usage = strings.TrimSpace(fmt.Sprintf("%s (default %v)", usage, fl.DefValue))
+		}
+
+		if usage != "" {
+			sb.WriteString("\n    \t")
+			sb.WriteString(strings.ReplaceAll(usage, "\n", "\n    \t"))
+		}
+
+		fmt.Fprintln(out, sb.String())
+	})
+}
+
+func dashes(name string) string {
+	if len(name) == 1 {
+		return "-"
+	}
+
+	return "--"
+}
+
+// isZeroValue reports whether the default value of the flag is the zero value of its type,
+// as done by flag.PrintDefaults.
+func isZeroValue(fl *flag.Flag) bool {
+	typ := reflect.TypeOf(fl.Value)
+
+	var z reflect.Value
+	if typ.Kind() == reflect.Pointer {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+
+	v, ok := z.Interface().(flag.Value)
+	return ok && v.String() == fl.DefValue
+}
+
+func isBoolFlag(fl *flag.Flag) bool {
+	bf, ok := fl.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heetch/confita/backend"
+)
+
+// dataDir is the symlink used by Kubernetes to atomically swap
+// the content of mounted secrets and ConfigMaps.
+const dataDir = "..data"
+
+// Backend loads keys from a directory holding one file per key, such as
+// Kubernetes secrets and ConfigMaps mounted as volumes, Docker secrets
+// or systemd credentials.
+type Backend struct {
+	dir     string
+	mapper  func(string) string
+	rawData bool
+}
+
+// NewBackend creates a configuration loader that loads keys from the files of dir.
+// The value of a key is the content of the file named after it, without its trailing newline.
+// Files are read every time a key is requested, so updates are always taken into account.
+func NewBackend(dir string, opts ...Option) *Backend {
+	b := Backend{
+		dir: dir,
+	}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+// Get reads the file corresponding to the given key.
+func (b *Backend) Get(ctx context.Context, key string) ([]byte, error) {
+	name := key
+	if b.mapper != nil {
+		name = b.mapper(key)
+	}
+
+	// only files stored directly in the directory can be read.
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return nil, backend.ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(b.root(), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, backend.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if !b.rawData {
+		data = trimNewline(data)
+	}
+
+	return data, nil
+}
+
+// root returns the directory the files must be read from. When the content is managed
+// by Kubernetes, it resolves the ..data symlink so that all the files are read from the
+// current version of the content, even if it's being swapped.
+func (b *Backend) root() string {
+	dir, err := filepath.EvalSymlinks(filepath.Join(b.dir, dataDir))
+	if err != nil {
+		return b.dir
+	}
+
+	return dir
+}
+
+func trimNewline(data []byte) []byte {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+		if n := len(data); n > 0 && data[n-1] == '\r' {
+			data = data[:n-1]
+		}
+	}
+
+	return data
+}
+
+// Name returns the name of the backend.
+func (b *Backend) Name() string {
+	return "secrets"
+}
+
+// Option is used to configure the secrets backend.
+type Option func(*Backend)
+
+// WithKeyMapper is used to specify how keys are turned into file names,
+// e.g. strings.ToUpper.
+func WithKeyMapper(fn func(key string) string) Option {
+	return func(b *Backend) {
+		b.mapper = fn
+	}
+}
+
+// WithRawData is used to keep the trailing newline of the files.
+func WithRawData() Option {
+	return func(b *Backend) {
+		b.rawData = true
+	}
+}
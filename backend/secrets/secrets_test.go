@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heetch/confita/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretsBackend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "confita")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db-password"), []byte("secret\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "API_KEY"), []byte("key\r\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "multi-line"), []byte("a\nb\n\n"), 0600))
+
+	t.Run("OK", func(t *testing.T) {
+		b := NewBackend(dir)
+
+		val, err := b.Get(context.Background(), "db-password")
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(val))
+
+		val, err = b.Get(context.Background(), "multi-line")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\n", string(val))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		b := NewBackend(dir)
+
+		for _, key := range []string{"something that doesn't exist", "../" + filepath.Base(dir) + "/db-password", ".", ""} {
+			_, err := b.Get(context.Background(), key)
+			require.Equal(t, backend.ErrNotFound, err)
+		}
+	})
+
+	t.Run("KeyMapper", func(t *testing.T) {
+		b := NewBackend(dir, WithKeyMapper(func(key string) string {
+			return strings.ToUpper(strings.Replace(key, "-", "_", -1))
+		}))
+
+		val, err := b.Get(context.Background(), "api-key")
+		require.NoError(t, err)
+		require.Equal(t, "key", string(val))
+	})
+
+	t.Run("RawData", func(t *testing.T) {
+		b := NewBackend(dir, WithRawData())
+
+		val, err := b.Get(context.Background(), "db-password")
+		require.NoError(t, err)
+		require.Equal(t, "secret\n", string(val))
+	})
+}
+
+func TestSecretsBackendKubernetes(t *testing.T) {
+	dir, err := os.MkdirTemp("", "confita")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// reproduce the layout of a volume managed by Kubernetes
+	write := func(version, value string) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, version, "password"), []byte(value), 0600))
+
+		tmp := filepath.Join(dir, "..data_tmp")
+		require.NoError(t, os.Symlink(version, tmp))
+		require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+	}
+
+	write("..v1", "first")
+	require.NoError(t, os.Symlink(filepath.Join("..data", "password"), filepath.Join(dir, "password")))
+
+	b := NewBackend(dir)
+
+	val, err := b.Get(context.Background(), "password")
+	require.NoError(t, err)
+	require.Equal(t, "first", string(val))
+
+	write("..v2", "second")
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "..v1")))
+
+	val, err = b.Get(context.Background(), "password")
+	require.NoError(t, err)
+	require.Equal(t, "second", string(val))
+
+	_, err = b.Get(context.Background(), "..data")
+	require.Equal(t, backend.ErrNotFound, err)
+}
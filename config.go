@@ -2,9 +2,12 @@ package confita
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +24,17 @@ type Loader struct {
 	// configuration keys and options.
 	// If empty, "config" is used.
 	Tag string
+
+	// ExtendedDurations allows time.Duration fields to be expressed
+	// using the "d" and "w" units (e.g. "7d", "1w2d12h")
+	// or as ISO 8601 durations (e.g. "P1DT2H").
+	// If false, only the time.ParseDuration syntax is accepted.
+	ExtendedDurations bool
+
+	// Separator is used to join the segments of hierarchical keys,
+	// such as the keys of the elements of slices and maps of structs.
+	// If empty, "." is used.
+	Separator string
 }
 
 // Unmarshaler can be implemented by backends to receive the struct directly and load values into it.
@@ -63,12 +77,23 @@ func (l *Loader) Load(ctx context.Context, to any) error {
 
 	ref = ref.Elem()
 
-	s := l.parseStruct(ref)
+	s := l.parseStruct(ref, nil)
 	s.S = to
 	return l.resolve(ctx, s)
 }
 
-func (l *Loader) parseStruct(ref reflect.Value) *StructConfig {
+func (l *Loader) separator() string {
+	if l.Separator == "" {
+		return "."
+	}
+
+	return l.Separator
+}
+
+// parseStruct analyses the fields of the given struct.
+// The given path is prepended to the key of every field, it is used
+// when parsing the elements of slices and maps of structs.
+func (l *Loader) parseStruct(ref reflect.Value, path []string) *StructConfig {
 	var s StructConfig
 
 	t := ref.Type()
@@ -101,11 +126,11 @@ func (l *Loader) parseStruct(ref reflect.Value) *StructConfig {
 			if typ == timeType {
 				break
 			}
-			s.Fields = append(s.Fields, l.parseStruct(value).Fields...)
+			s.Fields = append(s.Fields, l.parseStruct(value, path).Fields...)
 			continue
 		case reflect.Pointer:
 			if typ.Elem().Kind() == reflect.Struct && !value.IsNil() {
-				s.Fields = append(s.Fields, l.parseStruct(value.Elem()).Fields...)
+				s.Fields = append(s.Fields, l.parseStruct(value.Elem(), path).Fields...)
 				continue
 			}
 		}
@@ -116,9 +141,11 @@ func (l *Loader) parseStruct(ref reflect.Value) *StructConfig {
 		}
 
 		f := FieldConfig{
-			Name:  field.Name,
-			Key:   tag,
-			Value: value,
+			Name:              field.Name,
+			Key:               tag,
+			Value:             value,
+			Composite:         isComposite(typ),
+			extendedDurations: l.ExtendedDurations,
 		}
 
 		// copying field content to a new value
@@ -148,10 +175,33 @@ func (l *Loader) parseStruct(ref reflect.Value) *StructConfig {
 
 				if strings.HasPrefix(opt, "backend=") {
 					f.Backend = opt[len("backend="):]
+					continue
+				}
+
+				if strings.HasPrefix(opt, "layout=") {
+					f.Layout = opt[len("layout="):]
+					continue
+				}
+
+				if strings.HasPrefix(opt, "tz=") {
+					f.TimeZone = opt[len("tz="):]
+					continue
+				}
+
+				if strings.HasPrefix(opt, "encoding=") {
+					f.Encoding = opt[len("encoding="):]
+					continue
+				}
+
+				if strings.HasPrefix(opt, "sep=") {
+					f.ListSeparator = opt[len("sep="):]
 				}
 			}
 		}
 
+		f.Path = append(path[:len(path):len(path)], f.Key)
+		f.Key = strings.Join(f.Path, l.separator())
+
 		s.Fields = append(s.Fields, &f)
 	}
 
@@ -218,23 +268,198 @@ func (l *Loader) resolve(ctx context.Context, s *StructConfig) error {
 				continue
 			}
 
-			raw, err := b.Get(ctx, f.Key)
+			found, err := l.resolveField(ctx, b, f)
 			if err != nil {
-				if err == backend.ErrNotFound {
-					continue
-				}
 				return err
 			}
+			if found {
+				foundFields[f] = true
+			}
+		}
+	}
+
+	err := checkRequired(s.Fields)
+	if err != nil {
+		return err
+	}
+
+	return l.checkKeys(ctx, s.Fields)
+}
+
+// checkKeys lets the backends implementing backend.KeyChecker report the values
+// that don't correspond to any field.
+func (l *Loader) checkKeys(ctx context.Context, fields []*FieldConfig) error {
+	var keys []string
+	for _, f := range fields {
+		keys = append(keys, f.Key)
+	}
 
-			err = convert(string(raw), f.Value)
+	for _, b := range l.backends {
+		c, ok := b.(backend.KeyChecker)
+		if !ok {
+			continue
+		}
+
+		err := c.CheckKeys(ctx, keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveField loads the given field from b and reports whether it was found.
+func (l *Loader) resolveField(ctx context.Context, b backend.Backend, f *FieldConfig) (bool, error) {
+	if f.Composite {
+		return l.resolveComposite(ctx, b, f)
+	}
+
+	// times decoded by the backend are stored as is, whatever the layout of the field.
+	if vg, ok := b.(backend.ValueGetter); ok && f.Value.Type() == timeType {
+		v, err := vg.GetValue(ctx, f.Key)
+		if err != nil {
+			if err == backend.ErrNotFound {
+				return false, nil
+			}
+			return false, err
+		}
+
+		if t, ok := v.(time.Time); ok {
+			return true, f.setTime(t)
+		}
+	}
+
+	var (
+		raw []byte
+		err error
+	)
+	if tg, ok := b.(backend.TypedGetter); ok {
+		raw, err = tg.GetTyped(ctx, f.Key, f.Value.Type())
+	} else {
+		raw, err = b.Get(ctx, f.Key)
+	}
+	if err != nil {
+		if err == backend.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, f.Set(string(raw))
+}
+
+// resolveComposite loads a slice or a map of structs from the keys listed by b under the field key.
+// Each child of the key is an element of the slice, identified by its index, or an element of the map,
+// identified by its key. All the fields of an element are loaded from b.
+func (l *Loader) resolveComposite(ctx context.Context, b backend.Backend, f *FieldConfig) (bool, error) {
+	lister, ok := b.(backend.Lister)
+	if !ok {
+		return false, nil
+	}
+
+	sep := l.separator()
+	prefix := f.Key + sep
+
+	keys, err := lister.List(ctx, prefix)
+	if err != nil {
+		if err == backend.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var children []string
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		child := k[len(prefix):]
+		if idx := strings.Index(child, sep); idx != -1 {
+			child = child[:idx]
+		}
+
+		if child == "" || seen[child] {
+			continue
+		}
+		seen[child] = true
+		children = append(children, child)
+	}
+
+	if len(children) == 0 {
+		return false, nil
+	}
+	sort.Strings(children)
+
+	t := f.Value.Type()
+	elemType := t.Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+
+	var v reflect.Value
+	var indexes []int
+	switch t.Kind() {
+	case reflect.Slice:
+		// indexes must go from 0 to n-1, so that the size of the slice is bounded by the number of keys.
+		n := len(children)
+		for _, c := range children {
+			i, err := strconv.Atoi(c)
+			if err != nil || i < 0 || strconv.Itoa(i) != c {
+				return false, fmt.Errorf("invalid index '%s' for key '%s'", c, f.Key)
+			}
+			if i >= n {
+				return false, fmt.Errorf("index '%s' out of range for key '%s': indexes must go from 0 to %d", c, f.Key, n-1)
+			}
+			indexes = append(indexes, i)
+		}
+		v = reflect.MakeSlice(t, n, n)
+	case reflect.Map:
+		v = reflect.MakeMapWithSize(t, len(children))
+	}
+
+	for i, c := range children {
+		elem := reflect.New(structType).Elem()
+
+		sub := l.parseStruct(elem, append(f.Path[:len(f.Path):len(f.Path)], c))
+		for _, sf := range sub.Fields {
+			_, err := l.resolveField(ctx, b, sf)
 			if err != nil {
-				return err
+				return false, err
 			}
-			foundFields[f] = true
 		}
+
+		err := checkRequired(sub.Fields)
+		if err != nil {
+			return false, err
+		}
+
+		if elemType.Kind() == reflect.Pointer {
+			elem = elem.Addr()
+		}
+
+		if t.Kind() == reflect.Slice {
+			v.Index(indexes[i]).Set(elem)
+			continue
+		}
+
+		key := reflect.New(t.Key()).Elem()
+		err = f.convert(c, key)
+		if err != nil {
+			return false, err
+		}
+		v.SetMapIndex(key, elem)
 	}
 
-	for _, f := range s.Fields {
+	f.Value.Set(v)
+	return true, nil
+}
+
+func checkRequired(fields []*FieldConfig) error {
+	for _, f := range fields {
 		if f.Required && isZero(f.Value) {
 			return fmt.Errorf("required key '%s' for field '%s' not found", f.Key, f.Name)
 		}
@@ -251,28 +476,125 @@ type StructConfig struct {
 
 // FieldConfig holds informations about a struct field.
 type FieldConfig struct {
-	Name        string
-	Short       string
-	Key         string
+	Name  string
+	Short string
+	Key   string
+	// Path holds the segments Key is made of. Fields of structs stored
+	// in slices or maps are prefixed by the key of their parent field
+	// and the index or map key of their element, e.g. ["upstreams", "0", "host"].
+	Path []string
+	// Composite is true for slices and maps of structs. Such fields are loaded from
+	// the keys found under Key rather than from Key itself.
+	Composite   bool
 	Description string
 	Value       reflect.Value
 	Default     reflect.Value
 	Required    bool
 	Backend     string
+	// Layout is used to parse time.Time fields. It is either a Go time layout
+	// or one of the names listed in timeLayouts. Defaults to RFC3339.
+	Layout string
+	// TimeZone is the IANA name of the location used to interpret
+	// time.Time values that don't carry their own offset.
+	TimeZone string
+	// Encoding is used to decode byte slices and arrays.
+	// It can be "hex", "base64" or "base64url".
+	Encoding string
+	// ListSeparator is used to split the values of slices and arrays.
+	// If empty, "," is used.
+	ListSeparator string
+
+	extendedDurations bool
 }
 
 // Set converts data into f.Value.
 func (f *FieldConfig) Set(data string) error {
-	return convert(data, f.Value)
+	return f.convert(data, f.Value)
 }
 
 var durationType = reflect.TypeOf(time.Duration(0))
 var timeType = reflect.TypeOf(time.Time{})
 
-func convert(data string, value reflect.Value) error {
+// timeLayouts holds the layouts that can be referred to by name
+// using the layout option.
+var timeLayouts = map[string]string{
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"rubydate":    time.RubyDate,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"rfc850":      time.RFC850,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"kitchen":     time.Kitchen,
+	"datetime":    time.DateTime,
+	"date":        time.DateOnly,
+	"time":        time.TimeOnly,
+}
+
+// location returns the location used to interpret the values without an explicit offset.
+func (f *FieldConfig) location() (*time.Location, error) {
+	if f.TimeZone == "" {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(f.TimeZone)
+}
+
+// setTime stores a time decoded by a backend. Times without an explicit offset, which decoders
+// such as the TOML one return in time.Local, are interpreted like the parsed ones.
+func (f *FieldConfig) setTime(t time.Time) error {
+	if t.Location() == time.Local {
+		loc, err := f.location()
+		if err != nil {
+			return err
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+
+	f.Value.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func (f *FieldConfig) parseTime(data string) (time.Time, error) {
+	loc, err := f.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch f.Layout {
+	case "unix", "unixms":
+		i, err := strconv.ParseInt(data, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if f.Layout == "unixms" {
+			return time.UnixMilli(i).In(loc), nil
+		}
+		return time.Unix(i, 0).In(loc), nil
+	}
+
+	layout := time.RFC3339
+	if f.Layout != "" {
+		layout = f.Layout
+		if l, ok := timeLayouts[strings.ToLower(f.Layout)]; ok {
+			layout = l
+		}
+	}
+
+	return time.ParseInLocation(layout, data, loc)
+}
+
+func (f *FieldConfig) convert(data string, value reflect.Value) error {
 	t := value.Type()
 	if t == durationType {
-		d, err := time.ParseDuration(data)
+		parse := time.ParseDuration
+		if f.extendedDurations {
+			parse = parseExtendedDuration
+		}
+		d, err := parse(data)
 		if err != nil {
 			return err
 		}
@@ -281,7 +603,7 @@ func convert(data string, value reflect.Value) error {
 	}
 
 	if t == timeType {
-		d, err := time.Parse(time.RFC3339, data)
+		d, err := f.parseTime(data)
 		if err != nil {
 			return err
 		}
@@ -289,6 +611,24 @@ func convert(data string, value reflect.Value) error {
 		return nil
 	}
 
+	if f.Encoding != "" && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8 {
+		b, err := decodeBytes(f.Encoding, data)
+		if err != nil {
+			return err
+		}
+
+		if t.Kind() == reflect.Slice {
+			value.Set(reflect.ValueOf(b).Convert(t))
+			return nil
+		}
+
+		if len(b) != t.Len() {
+			return fmt.Errorf("expected %d bytes, got %d", t.Len(), len(b))
+		}
+		reflect.Copy(value, reflect.ValueOf(b))
+		return nil
+	}
+
 	switch t.Kind() {
 	case reflect.Bool:
 		b, err := strconv.ParseBool(data)
@@ -297,27 +637,49 @@ func convert(data string, value reflect.Value) error {
 		}
 		value.SetBool(b)
 	case reflect.Slice:
-		var err error
+		ss, err := splitList(data, f.ListSeparator)
+		if err != nil {
+			return err
+		}
 		// create a new temporary slice to override the actual Value if it's not empty
-		nv := reflect.MakeSlice(value.Type(), 0, 0)
-		ss := strings.Split(data, ",")
+		nv := reflect.MakeSlice(value.Type(), 0, len(ss))
 		for _, s := range ss {
 			// create a new Value v based on the type of the slice
 			v := reflect.Indirect(reflect.New(t.Elem()))
 			// call convert to set the current value of the slice to v
-			err = convert(s, v)
+			err = f.convert(s, v)
+			if err != nil {
+				return err
+			}
 			// append v to the temporary slice
 			nv = reflect.Append(nv, v)
 		}
 		// Set the newly created temporary slice to the target Value
 		value.Set(nv)
-		return err
+	case reflect.Array:
+		ss, err := splitList(data, f.ListSeparator)
+		if err != nil {
+			return err
+		}
+		if len(ss) != t.Len() {
+			return fmt.Errorf("expected %d elements, got %d", t.Len(), len(ss))
+		}
+
+		// fill a temporary array so that the field is left untouched on error
+		nv := reflect.Indirect(reflect.New(t))
+		for i, s := range ss {
+			err := f.convert(s, nv.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		value.Set(nv)
 	case reflect.String:
 		value.SetString(data)
 	case reflect.Pointer:
 		n := reflect.New(value.Type().Elem())
 		value.Set(n)
-		return convert(data, n.Elem())
+		return f.convert(data, n.Elem())
 	case reflect.Int,
 		reflect.Int8,
 		reflect.Int16,
@@ -353,6 +715,33 @@ func convert(data string, value reflect.Value) error {
 	return nil
 }
 
+func decodeBytes(encoding, data string) ([]byte, error) {
+	switch encoding {
+	case "hex":
+		return hex.DecodeString(data)
+	case "base64":
+		return base64.StdEncoding.DecodeString(data)
+	case "base64url":
+		return base64.URLEncoding.DecodeString(data)
+	default:
+		return nil, fmt.Errorf("encoding '%s' not supported", encoding)
+	}
+}
+
+// isComposite reports whether t is a slice or a map of structs or struct pointers.
+func isComposite(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return false
+	}
+
+	elem := t.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+
+	return elem.Kind() == reflect.Struct && elem != timeType
+}
+
 func isZero(v reflect.Value) bool {
 	zero := reflect.Zero(v.Type()).Interface()
 	current := v.Interface()
@@ -3,14 +3,17 @@ package confita_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"math"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/heetch/confita"
 	"github.com/heetch/confita/backend"
+	"github.com/heetch/confita/backend/env"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -26,6 +29,17 @@ func (s store) Get(ctx context.Context, key string) ([]byte, error) {
 	return []byte(data), nil
 }
 
+func (s store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for k := range s {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
 func (store) Name() string {
 	return "store"
 }
@@ -322,6 +336,87 @@ func (s *structLoader) LoadStruct(ctx context.Context, cfg *confita.StructConfig
 	return nil
 }
 
+type watcher struct {
+	store
+	ch chan struct{}
+}
+
+func (w *watcher) Watch(ctx context.Context) (<-chan struct{}, error) {
+	out := make(chan struct{})
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.ch:
+				out <- struct{}{}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		w1 := watcher{store: store{"name": "a"}, ch: make(chan struct{})}
+		w2 := watcher{store: store{"age": "10"}, ch: make(chan struct{})}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		l := confita.NewLoader(&w1, store{}, &w2)
+		ch, err := l.Watch(ctx)
+		require.NoError(t, err)
+
+		var s struct {
+			Name string `config:"name"`
+			Age  int    `config:"age"`
+		}
+
+		w1.store["name"] = "b"
+		w1.ch <- struct{}{}
+		<-ch
+		require.NoError(t, l.Load(ctx, &s))
+		require.Equal(t, "b", s.Name)
+
+		w2.store["age"] = "20"
+		w2.ch <- struct{}{}
+		<-ch
+		require.NoError(t, l.Load(ctx, &s))
+		require.Equal(t, 20, s.Age)
+
+		cancel()
+		for range ch {
+		}
+	})
+
+	t.Run("NoWatcher", func(t *testing.T) {
+		_, err := confita.NewLoader(store{}).Watch(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestCheckKeys(t *testing.T) {
+	var s struct {
+		Port    int           `config:"port"`
+		Timeout time.Duration `config:"timeout"`
+	}
+
+	b := env.NewBackendFrom(map[string]string{
+		"MYAPP_PORT":   "8080",
+		"MYAPP_TIMOUT": "10s",
+	}, env.WithPrefix("MYAPP_"), env.WithUnknownVariablesCheck())
+
+	err := confita.NewLoader(b).Load(context.Background(), &s)
+	require.EqualError(t, err, "unknown environment variables: MYAPP_TIMOUT (did you mean MYAPP_TIMEOUT?)")
+
+	var uerr *env.UnknownVariablesError
+	require.True(t, errors.As(err, &uerr))
+	require.Equal(t, 8080, s.Port)
+}
+
 func TestBackendTag(t *testing.T) {
 	type test struct {
 		Tikka  string `config:"tikka,backend=store"`
@@ -504,6 +599,104 @@ func TestSliceField(t *testing.T) {
 		require.EqualValues(t, e, s.Numbers)
 	})
 
+	t.Run("Whitespace", func(t *testing.T) {
+		s := struct {
+			Letters []string `config:"letters"`
+		}{}
+
+		st := store{
+			"letters": " a , b,c ",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"a", "b", "c"}, s.Letters)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		s := struct {
+			Letters []string `config:"letters"`
+		}{
+			Letters: []string{"a"},
+		}
+
+		st := store{
+			"letters": "",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.Empty(t, s.Letters)
+	})
+
+	t.Run("Custom separator", func(t *testing.T) {
+		s := struct {
+			DSNs    []string `config:"dsns,sep=;"`
+			Numbers []int    `config:"numbers,sep= | "`
+		}{}
+
+		st := store{
+			"dsns":    "postgres://a/db?sslmode=disable&x=1,2; postgres://b/db",
+			"numbers": "1 | 2 | 3",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"postgres://a/db?sslmode=disable&x=1,2", "postgres://b/db"}, s.DSNs)
+		require.EqualValues(t, []int{1, 2, 3}, s.Numbers)
+	})
+
+	t.Run("Quoted", func(t *testing.T) {
+		s := struct {
+			Patterns []string `config:"patterns"`
+		}{}
+
+		st := store{
+			"patterns": `"^a{1,3}$", " padded ", "say ""hi""",plain`,
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"^a{1,3}$", " padded ", `say "hi"`, "plain"}, s.Patterns)
+	})
+
+	t.Run("JSON array", func(t *testing.T) {
+		s := struct {
+			Letters []string  `config:"letters"`
+			Numbers []float64 `config:"numbers"`
+			Matrix  [][]int   `config:"matrix"`
+			Fixed   [2]string `config:"fixed"`
+		}{}
+
+		st := store{
+			"letters": `["a,b", "c"]`,
+			"numbers": `[1, 2.5]`,
+			"matrix":  `[[1, 2], [3]]`,
+			"fixed":   `["x", "y"]`,
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"a,b", "c"}, s.Letters)
+		require.EqualValues(t, []float64{1, 2.5}, s.Numbers)
+		require.EqualValues(t, [][]int{{1, 2}, {3}}, s.Matrix)
+		require.EqualValues(t, [2]string{"x", "y"}, s.Fixed)
+	})
+
+	t.Run("Not a JSON array", func(t *testing.T) {
+		s := struct {
+			Patterns []string `config:"patterns"`
+		}{}
+
+		st := store{
+			"patterns": "[a-z]+,[0-9]",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"[a-z]+", "[0-9]"}, s.Patterns)
+	})
+
 	t.Run("Slice of *int", func(t *testing.T) {
 		s := struct {
 			Numbers []*int `config:"numbers"`
@@ -521,6 +714,326 @@ func TestSliceField(t *testing.T) {
 	})
 }
 
+func TestTimeLayout(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	s := struct {
+		Default  time.Time  `config:"default"`
+		Date     time.Time  `config:"date,layout=date"`
+		DateTZ   time.Time  `config:"date,layout=date,tz=Europe/Paris"`
+		Custom   time.Time  `config:"custom,layout=02/01/2006 15h04"`
+		RFC1123  time.Time  `config:"rfc1123,layout=rfc1123"`
+		Unix     time.Time  `config:"unix,layout=unix"`
+		UnixMs   *time.Time `config:"unixms,layout=unixms"`
+		UnixTZ   time.Time  `config:"unix,layout=unix,tz=Europe/Paris"`
+		Kitchen  time.Time  `config:"kitchen,layout=Kitchen"`
+		Untagged time.Time
+	}{}
+
+	st := store{
+		"default": "2019-03-04T10:11:12Z",
+		"date":    "2019-03-04",
+		"custom":  "04/03/2019 10h11",
+		"rfc1123": "Mon, 04 Mar 2019 10:11:12 UTC",
+		"unix":    "1551694272",
+		"unixms":  "1551694272500",
+		"kitchen": "3:04PM",
+	}
+
+	err = confita.NewLoader(st).Load(context.Background(), &s)
+	require.NoError(t, err)
+
+	ref := time.Date(2019, 3, 4, 10, 11, 12, 0, time.UTC)
+	require.True(t, ref.Equal(s.Default))
+	require.Equal(t, time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC), s.Date)
+	require.Equal(t, time.Date(2019, 3, 4, 0, 0, 0, 0, paris), s.DateTZ)
+	require.Equal(t, time.Date(2019, 3, 4, 10, 11, 0, 0, time.UTC), s.Custom)
+	require.True(t, ref.Equal(s.RFC1123))
+	require.Equal(t, ref, s.Unix)
+	require.Equal(t, ref.Add(500*time.Millisecond), *s.UnixMs)
+	require.True(t, ref.Equal(s.UnixTZ))
+	require.Equal(t, paris, s.UnixTZ.Location())
+	require.Equal(t, 15, s.Kitchen.Hour())
+	require.Zero(t, s.Untagged)
+}
+
+func TestExtendedDurations(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		in  string
+		out time.Duration
+	}{
+		{"0", 0},
+		{"10s", 10 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"7d", 7 * day},
+		{"1.5d", 36 * time.Hour},
+		{"2w", 14 * day},
+		{"1w2d12h30m", 9*day + 12*time.Hour + 30*time.Minute},
+		{"-1d", -day},
+		{"P1D", day},
+		{"P1DT2H", day + 2*time.Hour},
+		{"PT1H30M", 90 * time.Minute},
+		{"PT0.5S", 500 * time.Millisecond},
+		{"PT1,5M", 90 * time.Second},
+		{"P2W", 14 * day},
+		{"-PT10S", -10 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			s := struct {
+				D time.Duration `config:"d"`
+			}{}
+
+			l := confita.NewLoader(store{"d": test.in})
+			l.ExtendedDurations = true
+			err := l.Load(context.Background(), &s)
+			require.NoError(t, err)
+			require.Equal(t, test.out, s.D)
+		})
+	}
+
+	for _, in := range []string{"", "d", "7", "7x", "P", "PT", "P1Y", "P1M", "PT1D", "P1H", "P1DT", "P1D1"} {
+		t.Run("invalid "+in, func(t *testing.T) {
+			s := struct {
+				D time.Duration `config:"d"`
+			}{}
+
+			l := confita.NewLoader(store{"d": in})
+			l.ExtendedDurations = true
+			err := l.Load(context.Background(), &s)
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("StrictByDefault", func(t *testing.T) {
+		s := struct {
+			D time.Duration `config:"d"`
+		}{}
+
+		err := confita.NewLoader(store{"d": "7d"}).Load(context.Background(), &s)
+		require.Error(t, err)
+	})
+
+	for _, in := range []string{"20000w", "P20000W", "15250w1w", "2562047h1h", "PT2562047H3600S"} {
+		t.Run("overflow "+in, func(t *testing.T) {
+			s := struct {
+				D time.Duration `config:"d"`
+			}{}
+
+			l := confita.NewLoader(store{"d": in})
+			l.ExtendedDurations = true
+			err := l.Load(context.Background(), &s)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "overflow")
+		})
+	}
+}
+
+func TestCompositeField(t *testing.T) {
+	type upstream struct {
+		Host    string        `config:"host,required"`
+		Port    int           `config:"port"`
+		Timeout time.Duration `config:"timeout"`
+	}
+
+	t.Run("Slice", func(t *testing.T) {
+		s := struct {
+			Upstreams []upstream `config:"upstreams"`
+		}{
+			Upstreams: []upstream{{Host: "default"}},
+		}
+
+		st := store{
+			"upstreams.0.host":  "a",
+			"upstreams.0.port":  "80",
+			"upstreams.1.host":  "b",
+			"upstreams.10.host": "c",
+			"upstreams.2.host":  "d",
+			"upstreams.3.host":  "e",
+			"upstreams.4.host":  "f",
+			"upstreams.5.host":  "g",
+			"upstreams.6.host":  "h",
+			"upstreams.7.host":  "i",
+			"upstreams.8.host":  "j",
+			"upstreams.9.host":  "k",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.Len(t, s.Upstreams, 11)
+		require.Equal(t, upstream{Host: "a", Port: 80}, s.Upstreams[0])
+		require.Equal(t, upstream{Host: "b"}, s.Upstreams[1])
+		require.Equal(t, upstream{Host: "k"}, s.Upstreams[9])
+		require.Equal(t, upstream{Host: "c"}, s.Upstreams[10])
+	})
+
+	t.Run("SliceOfPointers", func(t *testing.T) {
+		s := struct {
+			Upstreams []*upstream `config:"upstreams"`
+		}{}
+
+		st := store{
+			"upstreams.0.host":    "a",
+			"upstreams.1.host":    "b",
+			"upstreams.1.timeout": "1s",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.Equal(t, []*upstream{{Host: "a"}, {Host: "b", Timeout: time.Second}}, s.Upstreams)
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		s := struct {
+			Upstreams map[string]upstream `config:"upstreams"`
+		}{}
+
+		st := store{
+			"upstreams/primary/host":   "a",
+			"upstreams/primary/port":   "80",
+			"upstreams/secondary/host": "b",
+		}
+
+		l := confita.NewLoader(st)
+		l.Separator = "/"
+		err := l.Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.Equal(t, map[string]upstream{
+			"primary":   {Host: "a", Port: 80},
+			"secondary": {Host: "b"},
+		}, s.Upstreams)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		type cluster struct {
+			Name  string            `config:"name"`
+			Nodes map[int]*upstream `config:"nodes"`
+		}
+
+		s := struct {
+			Clusters []cluster `config:"clusters"`
+		}{}
+
+		st := store{
+			"clusters.0.name":         "eu",
+			"clusters.0.nodes.1.host": "a",
+			"clusters.0.nodes.2.host": "b",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.Equal(t, []cluster{{
+			Name: "eu",
+			Nodes: map[int]*upstream{
+				1: {Host: "a"},
+				2: {Host: "b"},
+			},
+		}}, s.Clusters)
+	})
+
+	t.Run("FirstBackendWins", func(t *testing.T) {
+		s := struct {
+			Upstreams []upstream `config:"upstreams"`
+		}{}
+
+		st1 := store{
+			"upstreams.0.host": "a",
+		}
+		st2 := store{
+			"upstreams.0.host": "b",
+			"upstreams.0.port": "80",
+			"upstreams.1.host": "c",
+		}
+
+		err := confita.NewLoader(st1, st2).Load(context.Background(), &s)
+		require.NoError(t, err)
+		require.Equal(t, []upstream{{Host: "a"}}, s.Upstreams)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		s := struct {
+			Upstreams []upstream `config:"upstreams,required"`
+		}{}
+
+		err := confita.NewLoader(store{"upstreamsx.0.host": "a"}).Load(context.Background(), &s)
+		require.EqualError(t, err, "required key 'upstreams' for field 'Upstreams' not found")
+	})
+
+	t.Run("RequiredElementField", func(t *testing.T) {
+		s := struct {
+			Upstreams []upstream `config:"upstreams"`
+		}{}
+
+		err := confita.NewLoader(store{"upstreams.0.port": "80"}).Load(context.Background(), &s)
+		require.EqualError(t, err, "required key 'upstreams.0.host' for field 'Host' not found")
+	})
+
+	t.Run("BadIndex", func(t *testing.T) {
+		s := struct {
+			Upstreams []upstream `config:"upstreams"`
+		}{}
+
+		err := confita.NewLoader(store{"upstreams.first.host": "a"}).Load(context.Background(), &s)
+		require.EqualError(t, err, "invalid index 'first' for key 'upstreams'")
+	})
+
+	t.Run("IndexOutOfRange", func(t *testing.T) {
+		s := struct {
+			Upstreams []upstream `config:"upstreams"`
+		}{}
+
+		st := store{
+			"upstreams.0.host":             "a",
+			"upstreams.9999999999999.host": "b",
+		}
+
+		err := confita.NewLoader(st).Load(context.Background(), &s)
+		require.EqualError(t, err, "index '9999999999999' out of range for key 'upstreams': indexes must go from 0 to 1")
+
+		err = confita.NewLoader(store{"upstreams.01.host": "a"}).Load(context.Background(), &s)
+		require.EqualError(t, err, "invalid index '01' for key 'upstreams'")
+	})
+}
+
+func TestArrayField(t *testing.T) {
+	s := struct {
+		Letters [3]string  `config:"letters"`
+		Numbers [2]*int    `config:"numbers"`
+		Raw     [3]byte    `config:"raw"`
+		Key     [4]byte    `config:"key,encoding=hex"`
+		Salt    [4]byte    `config:"salt,encoding=base64"`
+		URLSalt [4]uint8   `config:"urlsalt,encoding=base64url"`
+		Bytes   []byte     `config:"key,encoding=hex"`
+		Times   [1]float64 `config:"times"`
+	}{}
+
+	st := store{
+		"letters": "a,b,c",
+		"numbers": "21,42",
+		"raw":     "1,2,3",
+		"key":     "deadbeef",
+		"salt":    "3q2+7w==",
+		"urlsalt": "3q2-7w==",
+		"times":   "1.5",
+	}
+
+	err := confita.NewLoader(st).Load(context.Background(), &s)
+	require.NoError(t, err)
+	require.Equal(t, [3]string{"a", "b", "c"}, s.Letters)
+	require.Equal(t, 21, *s.Numbers[0])
+	require.Equal(t, 42, *s.Numbers[1])
+	require.Equal(t, [3]byte{1, 2, 3}, s.Raw)
+	require.Equal(t, [4]byte{0xde, 0xad, 0xbe, 0xef}, s.Key)
+	require.Equal(t, [4]byte{0xde, 0xad, 0xbe, 0xef}, s.Salt)
+	require.Equal(t, [4]uint8{0xde, 0xad, 0xbe, 0xef}, s.URLSalt)
+	require.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef}, s.Bytes)
+	require.Equal(t, [1]float64{1.5}, s.Times)
+}
+
 var errorTests = []struct {
 	testName    string
 	store       store
@@ -535,6 +1048,114 @@ var errorTests = []struct {
 		X time.Duration `config:"X"`
 	}),
 	expectError: `time: invalid duration "?xxxx"?`,
+}, {
+	testName: "bad-time-layout",
+	store: store{
+		"X": "2019-03-04T10:11:12Z",
+	},
+	into: new(struct {
+		X time.Time `config:"X,layout=date"`
+	}),
+	expectError: `parsing time "2019-03-04T10:11:12Z": extra text: "T10:11:12Z"`,
+}, {
+	testName: "bad-unix-time",
+	store: store{
+		"X": "yesterday",
+	},
+	into: new(struct {
+		X time.Time `config:"X,layout=unix"`
+	}),
+	expectError: `strconv.ParseInt: parsing "yesterday": invalid syntax`,
+}, {
+	testName: "bad-time-zone",
+	store: store{
+		"X": "2019-03-04",
+	},
+	into: new(struct {
+		X time.Time `config:"X,layout=date,tz=Mars/Olympus"`
+	}),
+	expectError: `unknown time zone Mars/Olympus`,
+}, {
+	testName: "array-too-short",
+	store: store{
+		"X": "a,b",
+	},
+	into: new(struct {
+		X [3]string `config:"X"`
+	}),
+	expectError: `expected 3 elements, got 2`,
+}, {
+	testName: "array-too-long",
+	store: store{
+		"X": "1,2,3,4",
+	},
+	into: new(struct {
+		X [3]int `config:"X"`
+	}),
+	expectError: `expected 3 elements, got 4`,
+}, {
+	testName: "bad-array-element",
+	store: store{
+		"X": "1,x,3",
+	},
+	into: new(struct {
+		X [3]int `config:"X"`
+	}),
+	expectError: `strconv.ParseInt: parsing "x": invalid syntax`,
+}, {
+	testName: "bad-byte-array-length",
+	store: store{
+		"X": "deadbeef",
+	},
+	into: new(struct {
+		X [16]byte `config:"X,encoding=hex"`
+	}),
+	expectError: `expected 16 bytes, got 4`,
+}, {
+	testName: "bad-hex",
+	store: store{
+		"X": "xyz",
+	},
+	into: new(struct {
+		X [16]byte `config:"X,encoding=hex"`
+	}),
+	expectError: `encoding/hex: invalid byte: U\+0078 'x'`,
+}, {
+	testName: "bad-encoding",
+	store: store{
+		"X": "xyz",
+	},
+	into: new(struct {
+		X [16]byte `config:"X,encoding=base32"`
+	}),
+	expectError: `encoding 'base32' not supported`,
+}, {
+	testName: "bad-slice-element",
+	store: store{
+		"X": "1,x,3",
+	},
+	into: new(struct {
+		X []int `config:"X"`
+	}),
+	expectError: `strconv.ParseInt: parsing "x": invalid syntax`,
+}, {
+	testName: "unterminated-quote",
+	store: store{
+		"X": `a,"b`,
+	},
+	into: new(struct {
+		X []string `config:"X"`
+	}),
+	expectError: `unterminated quoted value`,
+}, {
+	testName: "text-after-quote",
+	store: store{
+		"X": `"a"b,c`,
+	},
+	into: new(struct {
+		X []string `config:"X"`
+	}),
+	expectError: `unexpected character after quoted value`,
 }, {
 	testName: "bad-bool",
 	store: store{
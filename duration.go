@@ -0,0 +1,178 @@
+package confita
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// parseExtendedDuration parses a duration using either the time.ParseDuration syntax
+// extended with the "d" (day) and "w" (week) units, e.g. "1w2d12h",
+// or an ISO 8601 duration, e.g. "P1DT2H".
+// Days are always considered to be 24 hours long.
+func parseExtendedDuration(s string) (time.Duration, error) {
+	orig := s
+
+	var neg bool
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+
+	var (
+		d   time.Duration
+		err error
+	)
+	if s != "" && (s[0] == 'P' || s[0] == 'p') {
+		d, err = parseISODuration(s[1:])
+	} else {
+		d, err = parseUnitDuration(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %v", orig, err)
+	}
+
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// parseUnitDuration parses a sequence of numbers followed by a unit.
+func parseUnitDuration(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var d time.Duration
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i <= 0 {
+			return 0, fmt.Errorf("missing number")
+		}
+		num := s[:i]
+		s = s[i:]
+
+		j := strings.IndexFunc(s, func(r rune) bool {
+			return (r >= '0' && r <= '9') || r == '.'
+		})
+		if j == -1 {
+			j = len(s)
+		}
+		unit := s[:j]
+		s = s[j:]
+
+		switch unit {
+		case "d", "w":
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, err
+			}
+			u := day
+			if unit == "w" {
+				u = week
+			}
+			d, err = add(d, f*float64(u))
+			if err != nil {
+				return 0, err
+			}
+		default:
+			v, err := time.ParseDuration(num + unit)
+			if err != nil {
+				return 0, err
+			}
+			d, err = add(d, float64(v))
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// parseISODuration parses the part of an ISO 8601 duration following the "P" designator.
+// Years and months are rejected as they don't have a fixed length.
+func parseISODuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing duration elements")
+	}
+
+	var (
+		d      time.Duration
+		inTime bool
+	)
+	for s != "" {
+		if s[0] == 'T' || s[0] == 't' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("unexpected time designator")
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.' && r != ','
+		})
+		if i == -1 {
+			return 0, fmt.Errorf("missing designator")
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("missing number")
+		}
+		f, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+		if err != nil {
+			return 0, err
+		}
+
+		var u time.Duration
+		switch designator := strings.ToUpper(s[i : i+1]); {
+		case !inTime && designator == "W":
+			u = week
+		case !inTime && designator == "D":
+			u = day
+		case inTime && designator == "H":
+			u = time.Hour
+		case inTime && designator == "M":
+			u = time.Minute
+		case inTime && designator == "S":
+			u = time.Second
+		case !inTime && (designator == "Y" || designator == "M"):
+			return 0, fmt.Errorf("years and months are not supported")
+		default:
+			return 0, fmt.Errorf("unknown designator %q", designator)
+		}
+
+		d, err = add(d, f*float64(u))
+		if err != nil {
+			return 0, err
+		}
+		s = s[i+1:]
+	}
+
+	return d, nil
+}
+
+// add adds the positive number of nanoseconds v to d, reporting an overflow
+// like time.ParseDuration does.
+func add(d time.Duration, v float64) (time.Duration, error) {
+	// float64(math.MaxInt64) is rounded up to 1<<63
+	if v >= float64(math.MaxInt64) || d > math.MaxInt64-time.Duration(v) {
+		return 0, fmt.Errorf("overflow")
+	}
+
+	return d + time.Duration(v), nil
+}
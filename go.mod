@@ -5,6 +5,7 @@ require (
 	github.com/aws/aws-sdk-go v1.23.20
 	github.com/coreos/etcd v3.3.3+incompatible
 	github.com/hashicorp/consul/api v1.1.0
+	github.com/hashicorp/hcl v1.0.0
 	github.com/hashicorp/vault/api v1.0.4
 	github.com/pkg/errors v0.8.1
 	github.com/stretchr/testify v1.4.0
@@ -38,7 +39,6 @@ require (
 	github.com/hashicorp/go-rootcerts v1.0.1 // indirect
 	github.com/hashicorp/go-sockaddr v1.0.2 // indirect
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
-	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/hashicorp/serf v0.8.2 // indirect
 	github.com/hashicorp/vault/sdk v0.1.13 // indirect
 	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af // indirect
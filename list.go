@@ -0,0 +1,114 @@
+package confita
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// splitList splits the value of a slice or array field.
+// If data is a JSON array, its elements are returned, strings being unquoted
+// and other values kept as is. Otherwise data is split on sep, or "," if empty,
+// in a CSV fashion: each element is trimmed of surrounding whitespace unless
+// it's enclosed in double quotes, in which case it can contain the separator
+// and double quotes escaped by doubling them.
+func splitList(data, sep string) ([]string, error) {
+	if sep == "" {
+		sep = ","
+	}
+
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(data, "[") && strings.HasSuffix(data, "]") {
+		if ss, ok := splitJSONArray(data); ok {
+			return ss, nil
+		}
+	}
+
+	var (
+		ss       []string
+		cur      strings.Builder
+		quoted   bool
+		inQuotes bool
+	)
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case inQuotes:
+			if c != '"' {
+				cur.WriteByte(c)
+				continue
+			}
+			if i+1 < len(data) && data[i+1] == '"' {
+				cur.WriteByte('"')
+				i++
+				continue
+			}
+			inQuotes = false
+		case strings.HasPrefix(data[i:], sep):
+			if quoted {
+				ss = append(ss, cur.String())
+			} else {
+				ss = append(ss, strings.TrimSpace(cur.String()))
+			}
+			cur.Reset()
+			quoted = false
+			i += len(sep) - 1
+		case quoted:
+			if c != ' ' && c != '\t' {
+				return nil, errors.New("unexpected character after quoted value")
+			}
+		case c == '"' && strings.TrimSpace(cur.String()) == "":
+			cur.Reset()
+			quoted = true
+			inQuotes = true
+		default:
+			cur.WriteByte(c)
+		}
+	}
+
+	if inQuotes {
+		return nil, errors.New("unterminated quoted value")
+	}
+
+	if quoted {
+		ss = append(ss, cur.String())
+	} else {
+		ss = append(ss, strings.TrimSpace(cur.String()))
+	}
+
+	return ss, nil
+}
+
+// splitJSONArray returns the elements of the given JSON array and reports
+// whether data is a valid JSON array.
+func splitJSONArray(data string) ([]string, bool) {
+	var raw []json.RawMessage
+	err := json.Unmarshal([]byte(data), &raw)
+	if err != nil {
+		return nil, false
+	}
+
+	ss := make([]string, 0, len(raw))
+	for _, r := range raw {
+		var s string
+		if err := json.Unmarshal(r, &s); err == nil {
+			ss = append(ss, s)
+			continue
+		}
+
+		if string(r) == "null" {
+			ss = append(ss, "")
+			continue
+		}
+
+		ss = append(ss, string(r))
+	}
+
+	return ss, true
+}
@@ -0,0 +1,69 @@
+package confita
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/heetch/confita/backend"
+)
+
+// Watch watches the backends implementing backend.Watcher and sends a value on the returned channel
+// every time one of them reports a change, so that the configuration can be loaded again:
+//
+//	ch, err := loader.Watch(ctx)
+//	...
+//	for range ch {
+//		err = loader.Load(ctx, &cfg)
+//	}
+//
+// Notifications are coalesced if they are not consumed in time.
+// The channel is closed once ctx is done.
+func (l *Loader) Watch(ctx context.Context) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var chans []<-chan struct{}
+	for _, b := range l.backends {
+		w, ok := b.(backend.Watcher)
+		if !ok {
+			continue
+		}
+
+		ch, err := w.Watch(ctx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		chans = append(chans, ch)
+	}
+
+	if len(chans) == 0 {
+		cancel()
+		return nil, errors.New("none of the backends supports watching")
+	}
+
+	out := make(chan struct{}, 1)
+
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan struct{}) {
+			defer wg.Done()
+
+			for range ch {
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}(ch)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+
+	return out, nil
+}